@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// Export the data of the user as a ZIP archive of JSON files
+func ExportAccount(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	user, tasks, avatar, tokens, err := dao.GetAccountExport(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch account data", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not export account", true, http.StatusBadRequest)
+		return
+	}
+
+	currentToken := utils.HashToken(middlewares.RequestToken(c))
+	sessions := make([]models.SessionResponse, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, models.SessionResponse{
+			ID:         t.ID,
+			DeviceName: t.DeviceName,
+			UserAgent:  t.UserAgent,
+			IP:         t.IP,
+			CreatedAt:  t.Timestamp,
+			LastSeenAt: t.LastSeenAt,
+			ClientID:   t.ClientID,
+			Current:    t.UserTokenHash == currentToken,
+		})
+	}
+
+	files := map[string]any{
+		"profile.json": models.ExportProfile{
+			ID:            user.ID,
+			Name:          user.Name,
+			MobileNo:      user.MobileNo,
+			Gender:        user.Gender,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			CreatedAt:     user.CreatedAt,
+		},
+		"tasks.json":    tasks,
+		"sessions.json": sessions,
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err == nil {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(content)
+		}
+		if err != nil {
+			logger.Error(requestID, "could not write export archive", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+			utils.SetResponse(c, requestID, nil, "could not export account", true, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if avatar != nil {
+		w, err := zw.Create("avatar/" + filepath.Base(avatar.Name))
+		if err == nil {
+			_, err = w.Write(avatar.Data)
+		}
+		if err != nil {
+			logger.Error(requestID, "could not write export archive", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+			utils.SetResponse(c, requestID, nil, "could not export account", true, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		logger.Error(requestID, "could not write export archive", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not export account", true, http.StatusInternalServerError)
+		return
+	}
+
+	if err := dao.SaveAuditLog("account_exported", userId, c.ClientIP(), ""); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+	}
+
+	logger.Info(requestID, "account exported", "userID: "+strconv.Itoa(int(userId)))
+	c.Header("Content-Disposition", `attachment; filename="account_export_`+time.Now().Format("20060102")+`.zip"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+// Delete the account after confirming the password, hard deletion follows the grace period
+func DeleteAccount(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "password required", true, http.StatusBadRequest)
+		return
+	}
+
+	login, err := dao.GetUserByIdPassChng(userId)
+	if err != nil {
+		logger.Error(requestID, "User not found", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "user not found", true, http.StatusBadRequest)
+		return
+	}
+
+	//a stolen access token must not turn this into a password oracle
+	if reserveLoginAttempt(c, requestID, login.Email) {
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Password, login.Password) {
+		recordLoginFailure(c, requestID, login.Email)
+		logger.Warn(requestID, "incorrect password for account deletion", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "incorrect password", true, http.StatusBadRequest)
+		return
+	}
+	releaseLoginAttempt(c, requestID, login.Email)
+
+	grace := accountDeletionGrace()
+	if grace == 0 {
+		err = dao.DeleteAccount(userId)
+		if err != nil {
+			logger.Error(requestID, "failed to delete account", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+			utils.SetResponse(c, requestID, nil, "failed to delete account", true, http.StatusBadRequest)
+			return
+		}
+
+		if err := dao.SaveAuditLog("account_deleted", userId, "", ""); err != nil {
+			logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		}
+
+		clearSessionCookies(c)
+		logger.Info(requestID, "account deleted", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "account deleted", false, http.StatusOK)
+		return
+	}
+
+	deleteAt := time.Now().Add(grace)
+	err = dao.ScheduleAccountDeletion(userId, deleteAt)
+	if errors.Is(err, dao.ErrAccountDeletionScheduled) {
+		logger.Warn(requestID, "account deletion already scheduled", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to schedule account deletion", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to delete account", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.SaveAuditLog("account_deletion_scheduled", userId, c.ClientIP(), "delete at: "+deleteAt.UTC().Format(time.RFC3339)); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+	}
+
+	clearSessionCookies(c)
+	logger.Info(requestID, "account deletion scheduled", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, gin.H{"deletion_scheduled_at": deleteAt}, "account signed out everywhere and scheduled for deletion", false, http.StatusOK)
+}
+
+// Cancel a scheduled deletion, the password is confirmed since no session can be started before
+func RestoreAccount(c *gin.Context) {
+	requestID := requestid.Get(c)
+	var login models.Login
+
+	if err := c.ShouldBindJSON(&login); err != nil {
+		logger.Warn(requestID, "failed to parse restore request", err.Error())
+		utils.SetResponse(c, requestID, nil, "username and password required", true, http.StatusBadRequest)
+		return
+	}
+
+	err := utils.ValidateLoginDetails(login.Email, login.Password)
+	if err != nil {
+		logger.Warn(requestID, "Unable to validate user details", err.Error())
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	//password guesses here count like failed sign ins
+	if reserveLoginAttempt(c, requestID, login.Email) {
+		return
+	}
+
+	err = dao.ValidateCredentials(requestID, &login)
+	if err != nil {
+		recordLoginFailure(c, requestID, login.Email)
+		logger.Warn(requestID, "Authentication failed", err.Error())
+		utils.SetResponse(c, requestID, nil, "incorrect username or password", true, http.StatusBadRequest)
+		return
+	}
+	releaseLoginAttempt(c, requestID, login.Email)
+	recordLoginSuccess(requestID, login.Email)
+
+	err = dao.CancelAccountDeletion(login.ID)
+	if errors.Is(err, dao.ErrAccountDeletionNotScheduled) {
+		logger.Warn(requestID, "account deletion not scheduled", err.Error(), "userID: "+strconv.Itoa(int(login.ID)))
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to cancel account deletion", err.Error(), "userID: "+strconv.Itoa(int(login.ID)))
+		utils.SetResponse(c, requestID, nil, "failed to restore account", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.SaveAuditLog("account_deletion_cancelled", login.ID, c.ClientIP(), ""); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(login.ID)))
+	}
+
+	logger.Info(requestID, "account deletion cancelled", "userID: "+strconv.Itoa(int(login.ID)))
+	utils.SetResponse(c, requestID, nil, "account deletion cancelled, you can sign in again", false, http.StatusOK)
+}
+
+// Hard deletes accounts once their grace period is over
+func ScheduleAccountPurge() {
+	interval := utils.DurationFromEnv("ACCOUNT_PURGE_INTERVAL", time.Hour)
+	go func() {
+		purgeDeletedAccounts()
+		for range time.Tick(interval) {
+			purgeDeletedAccounts()
+		}
+	}()
+}
+
+func purgeDeletedAccounts() {
+	ids, err := dao.GetAccountsDueForDeletion(time.Now())
+	if err != nil {
+		logger.Error("", "could not fetch accounts due for deletion", err.Error())
+		return
+	}
+
+	for _, uid := range ids {
+		if err := dao.DeleteAccount(uid); err != nil {
+			logger.Error("", "could not delete account", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+			continue
+		}
+
+		if err := dao.SaveAuditLog("account_deleted", uid, "", ""); err != nil {
+			logger.Error("", "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		}
+		logger.Info("", "account deleted after grace period", "userID: "+strconv.Itoa(int(uid)))
+	}
+}
+
+// grace period from ACCOUNT_DELETION_GRACE, zero deletes accounts right away
+func accountDeletionGrace() time.Duration {
+	grace, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE"))
+	if err != nil || grace < 0 {
+		return 30 * 24 * time.Hour
+	}
+
+	return grace
+}
@@ -0,0 +1,373 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// page size of the user list when none or an invalid one is requested
+const defaultAdminPageSize = 20
+const maxAdminPageSize = 100
+
+// Promote the account of ADMIN_EMAIL to admin at startup while there is no admin yet
+func BootstrapAdmin() {
+	email := strings.TrimSpace(os.Getenv("ADMIN_EMAIL"))
+	if email == "" {
+		return
+	}
+
+	uid, err := dao.BootstrapAdmin(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Warn("", "no verified account for ADMIN_EMAIL, admin not bootstrapped", "")
+		return
+	}
+	if err != nil {
+		logger.Error("", "could not bootstrap admin", err.Error())
+		return
+	}
+	if uid == 0 {
+		return
+	}
+
+	if err := dao.SaveAuditLog("admin_bootstrapped", uid, "", ""); err != nil {
+		logger.Error("", "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+	}
+	logger.Info("", "admin bootstrapped", "userID: "+strconv.Itoa(int(uid)))
+}
+
+// List users, optionally filtered by a search on name and email
+func AdminListUsers(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	search := strings.TrimSpace(c.Query("search"))
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		logger.Warn(requestID, "Invalid page parameter", c.DefaultQuery("page", "1"), "userID: "+strconv.Itoa(int(userId)))
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAdminPageSize)))
+	if err != nil || limit < 1 || limit > maxAdminPageSize {
+		logger.Warn(requestID, "Invalid limit parameter", c.Query("limit"), "userID: "+strconv.Itoa(int(userId)))
+		limit = defaultAdminPageSize
+	}
+
+	users, total, err := dao.ListUsers(search, limit, (page-1)*limit)
+	if err != nil {
+		logger.Error(requestID, "could not fetch users", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch users", true, http.StatusBadRequest)
+		return
+	}
+
+	response := make([]models.AdminUserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, adminUserResponse(&user))
+	}
+
+	auditAdminAction(c, requestID, "admin_users_listed", userId, "search: "+search)
+
+	totalPages := (total + int64(limit) - 1) / int64(limit)
+	logger.Info(requestID, "users fetched successfully", "userID: "+strconv.Itoa(int(userId)), "page: "+strconv.Itoa(page), "limit: "+strconv.Itoa(limit))
+	utils.SetResponse(c, requestID, gin.H{"users": response, "total": total, "totalPages": totalPages, "currentPage": page}, "users fetched successfully", false, http.StatusOK)
+}
+
+// Fetch a single user
+func AdminGetUser(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	targetId, ok := adminTargetUser(c, requestID)
+	if !ok {
+		return
+	}
+
+	user, err := dao.GetUser(targetId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Warn(requestID, "user not found", err.Error(), "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+		utils.SetResponse(c, requestID, nil, "user not found", true, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "could not fetch user", err.Error(), "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch user", true, http.StatusBadRequest)
+		return
+	}
+
+	auditAdminAction(c, requestID, "admin_user_viewed", targetId, "")
+
+	logger.Info(requestID, "user fetched successfully", "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+	utils.SetResponse(c, requestID, adminUserResponse(user), "user fetched successfully", false, http.StatusOK)
+}
+
+// Disable an account, its sessions are signed out and no new ones can be started
+func AdminDisableUser(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	targetId, ok := adminTargetUser(c, requestID)
+	if !ok {
+		return
+	}
+
+	//admins cannot lock themselves out
+	if targetId == userId {
+		logger.Warn(requestID, "admin tried to disable own account", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "you cannot disable your own account", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.DisableUser(targetId)
+	if adminUserError(c, requestID, err, targetId, "failed to disable user") {
+		return
+	}
+
+	auditAdminAction(c, requestID, "admin_user_disabled", targetId, "")
+
+	logger.Info(requestID, "user disabled", "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+	utils.SetResponse(c, requestID, nil, "user disabled and signed out everywhere", false, http.StatusOK)
+}
+
+// Enable a disabled account
+func AdminEnableUser(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	targetId, ok := adminTargetUser(c, requestID)
+	if !ok {
+		return
+	}
+
+	err = dao.EnableUser(targetId)
+	if adminUserError(c, requestID, err, targetId, "failed to enable user") {
+		return
+	}
+
+	auditAdminAction(c, requestID, "admin_user_enabled", targetId, "")
+
+	logger.Info(requestID, "user enabled", "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+	utils.SetResponse(c, requestID, nil, "user enabled", false, http.StatusOK)
+}
+
+// Sign out every session of a user and revoke their personal access tokens
+func AdminSignOutUser(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	targetId, ok := adminTargetUser(c, requestID)
+	if !ok {
+		return
+	}
+
+	err = dao.SignOutEverywhere(targetId)
+	if err != nil {
+		logger.Error(requestID, "failed to signout user from all devices", err.Error(), "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+		utils.SetResponse(c, requestID, nil, "failed to signout user from all devices", true, http.StatusBadRequest)
+		return
+	}
+
+	auditAdminAction(c, requestID, "admin_user_signed_out", targetId, "")
+
+	logger.Info(requestID, "user signed out from all devices", "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+	utils.SetResponse(c, requestID, nil, "user signed out from all devices and personal access tokens revoked", false, http.StatusOK)
+}
+
+// Invalidate the password of a user and email them a reset link
+func AdminResetPassword(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	targetId, ok := adminTargetUser(c, requestID)
+	if !ok {
+		return
+	}
+
+	user, err := dao.GetUser(targetId)
+	if adminUserError(c, requestID, err, targetId, "failed to reset password") {
+		return
+	}
+
+	//the old password stops working, nobody knows the random one replacing it
+	random, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate password", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to reset password", true, http.StatusInternalServerError)
+		return
+	}
+	unusableHash, err := utils.HashPassword(random)
+	if err != nil {
+		logger.Error(requestID, "failed to hashed password", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to reset password", true, http.StatusInternalServerError)
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate reset token", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to reset password", true, http.StatusInternalServerError)
+		return
+	}
+
+	ttl := utils.DurationFromEnv("ADMIN_PASSWORD_RESET_TTL", 24*time.Hour)
+	err = dao.ForcePasswordReset(targetId, unusableHash, utils.HashToken(token), time.Now().Add(ttl))
+	if adminUserError(c, requestID, err, targetId, "failed to reset password") {
+		return
+	}
+
+	link := utils.AppURL() + "/reset-password?token=" + token
+	utils.SendMailAsync(requestID, user.Email, "Your password was reset",
+		"An administrator reset the password of your account and signed out all sessions. Use the link below to choose a new password. It expires in "+ttl.String()+" and can be used once.\n\n"+link)
+
+	auditAdminAction(c, requestID, "admin_password_reset", targetId, "")
+
+	logger.Info(requestID, "password reset by admin", "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+	utils.SetResponse(c, requestID, nil, "password reset, the user was emailed a link to choose a new one", false, http.StatusOK)
+}
+
+// Fetch counts of users, sessions and tasks
+func AdminGetStats(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	stats, err := dao.GetSystemStats()
+	if err != nil {
+		logger.Error(requestID, "could not fetch system stats", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch stats", true, http.StatusBadRequest)
+		return
+	}
+
+	auditAdminAction(c, requestID, "admin_stats_viewed", userId, "")
+
+	logger.Info(requestID, "system stats fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, stats, "stats fetched successfully", false, http.StatusOK)
+}
+
+// parse the user id of the path, responds and returns false when invalid
+func adminTargetUser(c *gin.Context, requestID string) (int64, bool) {
+	targetId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(requestID, "failed to parse user id", c.Param("id"), err.Error())
+		utils.SetResponse(c, requestID, nil, "could not parse user id", true, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return targetId, true
+}
+
+// respond to an error of an admin action on a user, returns true if there was one
+func adminUserError(c *gin.Context, requestID string, err error, targetId int64, message string) bool {
+	userId := c.GetInt64("userId")
+
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		logger.Warn(requestID, "user not found", err.Error(), "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+		utils.SetResponse(c, requestID, nil, "user not found", true, http.StatusNotFound)
+	case errors.Is(err, dao.ErrAccountDisabled) || errors.Is(err, dao.ErrAccountNotDisabled):
+		logger.Warn(requestID, message, err.Error(), "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusConflict)
+	default:
+		logger.Error(requestID, message, err.Error(), "userID: "+strconv.Itoa(int(userId)), "targetID: "+strconv.Itoa(int(targetId)))
+		utils.SetResponse(c, requestID, nil, message, true, http.StatusBadRequest)
+	}
+
+	return true
+}
+
+// audit an admin action, uid is the affected user and the admin is kept in the detail
+func auditAdminAction(c *gin.Context, requestID, event string, uid int64, detail string) {
+	adminId := c.GetInt64("userId")
+
+	entry := "admin: " + strconv.Itoa(int(adminId))
+	if detail != "" {
+		entry += ", " + detail
+	}
+	if err := dao.SaveAuditLog(event, uid, c.ClientIP(), entry); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(adminId)))
+	}
+}
+
+func adminUserResponse(user *dao.User) models.AdminUserResponse {
+	return models.AdminUserResponse{
+		ID:                  user.ID,
+		Name:                user.Name,
+		Email:               user.Email,
+		MobileNo:            user.MobileNo,
+		Role:                user.Role,
+		EmailVerified:       user.EmailVerified,
+		DisabledAt:          user.DisabledAt,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		CreatedAt:           user.CreatedAt,
+	}
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// create custom field definition for user
+func CreateCustomField(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	bodyBytes, _ := io.ReadAll(c.Request.Body)
+	requestBody := string(bodyBytes)
+
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var field models.CustomField
+	err = c.ShouldBindJSON(&field)
+	if err != nil {
+		logger.Error(requestID, "failed to parse request", err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, "cannot parsed the requested data", true, http.StatusBadRequest)
+		return
+	}
+
+	//validate field name, type and options
+	err = utils.ValidateCustomField(field)
+	if err != nil {
+		logger.Error(requestID, "invalid custom field", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.SaveCustomField(userId, &field)
+	if err != nil {
+		logger.Error(requestID, "failed to save custom field", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "failed to create custom field, name may already exist", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "custom field created successfully", "fieldID: "+strconv.Itoa(int(field.ID)), "userID: "+strconv.Itoa(int(userId)), requestBody)
+	utils.SetResponse(c, requestID, field, "custom field created successfully", false, http.StatusCreated)
+}
+
+// fetch all custom field definitions of user
+func GetCustomFields(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	fields, err := dao.GetCustomFields(userId)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch custom fields", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch custom fields", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "custom fields fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, fields, "custom fields fetched successfully", false, http.StatusOK)
+}
+
+// delete custom field definition and its values
+func DeleteCustomField(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	fieldId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(requestID, "failed to parse field id", c.Param("id"), err.Error())
+		utils.SetResponse(c, requestID, nil, "could not parse field id", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.DeleteCustomField(userId, fieldId)
+	if err != nil {
+		logger.Error(requestID, "failed to delete custom field", err.Error(), "userID: "+strconv.Itoa(int(userId)), "fieldID: "+strconv.Itoa(int(fieldId)))
+		utils.SetResponse(c, requestID, nil, "could not delete custom field", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "custom field deleted successfully", "userID: "+strconv.Itoa(int(userId)), "fieldID: "+strconv.Itoa(int(fieldId)))
+	utils.SetResponse(c, requestID, nil, "custom field deleted successfully", false, http.StatusOK)
+}
+
+// validate custom field values of task against user field definitions
+func resolveCustomFieldValues(userId int64, raw map[string]interface{}) (map[int64]*models.CustomFieldValue, error) {
+	values := map[int64]*models.CustomFieldValue{}
+	if len(raw) == 0 {
+		return values, nil
+	}
+
+	fields, err := dao.GetCustomFields(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]models.CustomField{}
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+
+	for name, v := range raw {
+		field, ok := byName[name]
+		if !ok {
+			return nil, errors.New("unknown custom field: " + name)
+		}
+
+		// null clears the value of the field
+		if v == nil {
+			values[field.ID] = nil
+			continue
+		}
+
+		value, err := utils.ParseCustomFieldValue(field, v)
+		if err != nil {
+			return nil, err
+		}
+		values[field.ID] = &value
+	}
+
+	return values, nil
+}
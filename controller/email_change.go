@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// same response whether or not the new address is already taken
+const emailChangeMessage = "confirm the change with the link sent to the new email"
+
+// Start an email change, the new address has to be confirmed before it is used
+func RequestEmailChange(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "new email and password required", true, http.StatusBadRequest)
+		return
+	}
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+
+	login, err := dao.GetUserByIdPassChng(userId)
+	if err != nil {
+		logger.Error(requestID, "User not found", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "user not found", true, http.StatusBadRequest)
+		return
+	}
+
+	err = utils.ValidateEmailChange(req.NewEmail, login.Email)
+	if err != nil {
+		logger.Warn(requestID, "unable to validate new email", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	//a stolen access token must not turn this into a password oracle
+	if reserveLoginAttempt(c, requestID, login.Email) {
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Password, login.Password) {
+		recordLoginFailure(c, requestID, login.Email)
+		logger.Warn(requestID, "incorrect password for email change", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "incorrect password", true, http.StatusBadRequest)
+		return
+	}
+	releaseLoginAttempt(c, requestID, login.Email)
+
+	inUse, err := dao.IsEmailInUse(req.NewEmail)
+	if err != nil {
+		logger.Error(requestID, "could not check email", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to change email", true, http.StatusBadRequest)
+		return
+	}
+	//same response as for a free address so accounts cannot be enumerated, confirming rejects it anyway
+	if inUse {
+		logger.Warn(requestID, "email change to an address in use", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, emailChangeMessage, false, http.StatusOK)
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate email change token", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to change email", true, http.StatusBadRequest)
+		return
+	}
+
+	ttl := utils.DurationFromEnv("EMAIL_CHANGE_TTL", 24*time.Hour)
+	err = dao.SaveEmailChange(&dao.EmailChange{
+		TokenHash: utils.HashToken(token),
+		NewEmail:  req.NewEmail,
+		ExpiresAt: time.Now().Add(ttl),
+		UserID:    login.UserID,
+	})
+	if err != nil {
+		logger.Error(requestID, "failed to save email change", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to change email", true, http.StatusBadRequest)
+		return
+	}
+
+	link := utils.AppURL() + "/user/email/confirm?token=" + token
+	utils.SendMailAsync(requestID, req.NewEmail, "Confirm your new email address",
+		"Use the link below to confirm this address for your account. It expires in "+ttl.String()+".\n\n"+link+
+			"\n\nIf you did not ask for this change, you can ignore this email.")
+	utils.SendMailAsync(requestID, login.Email, "Your email address is being changed",
+		"A change of the email address of your account to "+req.NewEmail+" was requested. It takes effect once the new address is confirmed.\n\n"+
+			"If you did not request this, change your password and sign out all sessions.")
+
+	if err := dao.SaveAuditLog("email_change_requested", login.UserID, c.ClientIP(), ""); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+	}
+
+	logger.Info(requestID, "email change requested", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, nil, emailChangeMessage, false, http.StatusOK)
+}
+
+// Confirm an email change with the token sent to the new address
+func ConfirmEmailChange(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	token := c.Query("token")
+	if token == "" {
+		logger.Warn(requestID, "email change token missing", "")
+		utils.SetResponse(c, requestID, nil, "email change token required", true, http.StatusBadRequest)
+		return
+	}
+
+	uid, oldEmail, newEmail, err := dao.ConfirmEmailChange(utils.HashToken(token))
+	if errors.Is(err, dao.ErrInvalidEmailChangeToken) || errors.Is(err, dao.ErrEmailInUse) {
+		logger.Warn(requestID, "email change rejected", err.Error())
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to change email", err.Error())
+		utils.SetResponse(c, requestID, nil, "failed to change email", true, http.StatusBadRequest)
+		return
+	}
+
+	utils.SendMailAsync(requestID, oldEmail, "Your email address was changed",
+		"The email address of your account was changed to "+newEmail+". Sign in with the new address from now on.\n\n"+
+			"If you did not make this change, contact support right away.")
+
+	if err := dao.SaveAuditLog("email_changed", uid, c.ClientIP(), ""); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+	}
+
+	logger.Info(requestID, "Email changed successfully", "userID: "+strconv.Itoa(int(uid)))
+	utils.SetResponse(c, requestID, nil, "email changed successfully", false, http.StatusOK)
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// verification emails allowed per user within an hour
+const maxVerificationEmailsPerHour = 5
+
+// Verify email address with the token sent by email
+func VerifyEmail(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	token := c.Query("token")
+	if token == "" {
+		logger.Warn(requestID, "verification token missing", "")
+		utils.SetResponse(c, requestID, nil, "verification token required", true, http.StatusBadRequest)
+		return
+	}
+
+	uid, err := dao.VerifyEmail(utils.HashToken(token))
+	if errors.Is(err, dao.ErrInvalidVerificationToken) {
+		logger.Warn(requestID, "invalid verification token", err.Error())
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to verify email", err.Error())
+		utils.SetResponse(c, requestID, nil, "failed to verify email", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "Email verified successfully", "userID: "+strconv.Itoa(int(uid)))
+	utils.SetResponse(c, requestID, nil, "email verified successfully", false, http.StatusOK)
+}
+
+// Resend the verification email, throttled per user
+func ResendVerification(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	user, err := dao.GetVerificationStatus(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch user", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch user", true, http.StatusBadRequest)
+		return
+	}
+
+	if user.EmailVerified {
+		logger.Warn(requestID, "email already verified", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "email already verified", true, http.StatusBadRequest)
+		return
+	}
+
+	count, lastSent, err := dao.GetRecentVerifications(userId, time.Now().Add(-time.Hour))
+	if err != nil {
+		logger.Error(requestID, "could not fetch verification history", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to resend verification email", true, http.StatusBadRequest)
+		return
+	}
+
+	interval := utils.DurationFromEnv("EMAIL_VERIFY_RESEND_INTERVAL", time.Minute)
+	if count >= maxVerificationEmailsPerHour || (lastSent != nil && time.Since(*lastSent) < interval) {
+		logger.Warn(requestID, "verification email throttled", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "verification email sent recently, please try again later", true, http.StatusTooManyRequests)
+		return
+	}
+
+	err = sendVerificationEmail(requestID, userId, user.Email)
+	if err != nil {
+		logger.Error(requestID, "failed to send verification email", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to resend verification email", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "verification email resent", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, nil, "verification email sent", false, http.StatusOK)
+}
+
+// create a verification token and email the verification link
+func sendVerificationEmail(requestID string, uid int64, email string) error {
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	ttl := utils.DurationFromEnv("EMAIL_VERIFY_TTL", 24*time.Hour)
+	err = dao.SaveEmailVerification(uid, utils.HashToken(token), time.Now().Add(ttl))
+	if err != nil {
+		return err
+	}
+
+	link := utils.AppURL() + "/user/verify?token=" + token
+	utils.SendMailAsync(requestID, email, "Verify your email address",
+		"Use the link below to verify your email address. It expires in "+ttl.String()+".\n\n"+link)
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// Unlock an account locked after failed logins with the token sent by email
+func UnlockAccount(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	token := c.Query("token")
+	if token == "" {
+		logger.Warn(requestID, "unlock token missing", "")
+		utils.SetResponse(c, requestID, nil, "unlock token required", true, http.StatusBadRequest)
+		return
+	}
+
+	uid, err := dao.UnlockAccount(utils.HashToken(token))
+	if errors.Is(err, dao.ErrInvalidUnlockToken) {
+		logger.Warn(requestID, "invalid unlock token", err.Error())
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to unlock account", err.Error())
+		utils.SetResponse(c, requestID, nil, "failed to unlock account", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.SaveAuditLog("account_unlocked", uid, c.ClientIP(), "unlocked by email token"); err != nil {
+		logger.Error(requestID, "failed to save audit log", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+	}
+
+	logger.Info(requestID, "Account unlocked successfully", "userID: "+strconv.Itoa(int(uid)))
+	utils.SetResponse(c, requestID, nil, "account unlocked successfully", false, http.StatusOK)
+}
+
+// time the client has to wait before the next login attempt for account or ip
+func loginRetryAfter(email, ip string) (time.Duration, error) {
+	protection := utils.GetLoginProtection()
+	now := time.Now()
+	var wait time.Duration
+
+	for _, key := range []string{utils.AccountAttemptKey(email), utils.IPAttemptKey(ip)} {
+		attempt, err := dao.GetLoginAttempt(key)
+		if err != nil {
+			return 0, err
+		}
+		if attempt == nil {
+			continue
+		}
+
+		wait = max(wait, protection.RetryAfter(attempt.Failures, attempt.LastFailureAt, attempt.LockedUntil, now))
+	}
+
+	return wait, nil
+}
+
+// reject the request with 429 when login attempts are throttled, returns true if rejected.
+// Only for requests that do not check a password or code, those reserve an attempt instead.
+func rejectThrottledLogin(c *gin.Context, requestID, email string) bool {
+	wait, err := loginRetryAfter(email, c.ClientIP())
+	if err != nil {
+		logger.Error(requestID, "failed to check login attempts", err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return true
+	}
+
+	return rejectLoginWait(c, requestID, wait)
+}
+
+// reserve an attempt for account and ip before checking a password or code, so parallel
+// requests cannot all pass the check before their failures are counted. Returns true if rejected.
+func reserveLoginAttempt(c *gin.Context, requestID, email string) bool {
+	keys := []string{utils.AccountAttemptKey(email), utils.IPAttemptKey(c.ClientIP())}
+	wait, err := dao.ReserveLoginAttempt(keys, utils.GetLoginProtection())
+	if err != nil {
+		logger.Error(requestID, "failed to reserve login attempt", err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return true
+	}
+
+	return rejectLoginWait(c, requestID, wait)
+}
+
+// take back the attempt reserved for account and ip once the password or code was correct
+func releaseLoginAttempt(c *gin.Context, requestID, email string) {
+	for _, key := range []string{utils.AccountAttemptKey(email), utils.IPAttemptKey(c.ClientIP())} {
+		if err := dao.ReleaseLoginAttempt(key); err != nil {
+			logger.Error(requestID, "failed to release login attempt", err.Error())
+		}
+	}
+}
+
+func rejectLoginWait(c *gin.Context, requestID string, wait time.Duration) bool {
+	if wait > 0 {
+		logger.Warn(requestID, "login throttled", "", "retryAfter: "+wait.String())
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		utils.SetResponse(c, requestID, nil, "too many failed attempts, try again later", true, http.StatusTooManyRequests)
+		return true
+	}
+
+	return false
+}
+
+// lock account and ip once the failures counted by their reserved attempt are over the threshold
+func recordLoginFailure(c *gin.Context, requestID, email string) {
+	protection := utils.GetLoginProtection()
+	ip := c.ClientIP()
+
+	attempt, err := dao.GetLoginAttempt(utils.AccountAttemptKey(email))
+	if err != nil {
+		logger.Error(requestID, "failed to record login failure", err.Error())
+	} else if attempt != nil && attempt.Failures >= protection.AccountThreshold && !isLocked(attempt) {
+		lockAccount(requestID, email, ip, attempt.Failures, protection.LockoutDuration)
+	}
+
+	attempt, err = dao.GetLoginAttempt(utils.IPAttemptKey(ip))
+	if err != nil {
+		logger.Error(requestID, "failed to record login failure", err.Error())
+	} else if attempt != nil && attempt.Failures >= protection.IPThreshold && !isLocked(attempt) {
+		if err := dao.LockLoginAttempt(attempt.AttemptKey, time.Now().Add(protection.LockoutDuration)); err != nil {
+			logger.Error(requestID, "failed to lock ip", err.Error())
+		}
+		if err := dao.SaveAuditLog("ip_locked", 0, ip, strconv.Itoa(attempt.Failures)+" failed logins"); err != nil {
+			logger.Error(requestID, "failed to save audit log", err.Error())
+		}
+		logger.Warn(requestID, "ip locked after failed logins", "", "ip: "+ip)
+	}
+}
+
+// forget failed logins of the account after a successful sign in
+func recordLoginSuccess(requestID, email string) {
+	if err := dao.ClearLoginAttempts(utils.AccountAttemptKey(email)); err != nil {
+		logger.Error(requestID, "failed to clear login attempts", err.Error())
+	}
+}
+
+// lock the account, audit it and email an unlock link if the account exists
+func lockAccount(requestID, email, ip string, failures int, duration time.Duration) {
+	key := utils.AccountAttemptKey(email)
+	if err := dao.LockLoginAttempt(key, time.Now().Add(duration)); err != nil {
+		logger.Error(requestID, "failed to lock account", err.Error())
+		return
+	}
+
+	login, err := dao.GetLoginByEmail(email)
+	if err != nil {
+		logger.Warn(requestID, "locked login attempts for unknown account", err.Error())
+		return
+	}
+
+	if err := dao.SaveAuditLog("account_locked", login.UserID, ip, strconv.Itoa(failures)+" failed logins"); err != nil {
+		logger.Error(requestID, "failed to save audit log", err.Error(), "userID: "+strconv.Itoa(int(login.UserID)))
+	}
+	logger.Warn(requestID, "account locked after failed logins", "", "userID: "+strconv.Itoa(int(login.UserID)))
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate unlock token", err.Error())
+		return
+	}
+
+	if err := dao.SaveAccountUnlock(login.UserID, utils.HashToken(token), time.Now().Add(duration)); err != nil {
+		logger.Error(requestID, "failed to save unlock token", err.Error())
+		return
+	}
+
+	link := utils.AppURL() + "/user/unlock?token=" + token
+	utils.SendMailAsync(requestID, login.Email, "Your account has been locked",
+		"Your account was locked for "+duration.String()+" after too many failed sign in attempts.\n\n"+
+			"If this was you, use the link below to unlock it now. Otherwise consider resetting your password.\n\n"+link)
+}
+
+func isLocked(attempt *dao.LoginAttempt) bool {
+	return attempt.LockedUntil != nil && time.Now().Before(*attempt.LockedUntil)
+}
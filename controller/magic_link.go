@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// cookie binding a sign in link to the browser that requested it
+const magicLinkCookie = "magic_link_nonce"
+
+// sign in links sent per account and requested per ip in an hour
+const maxMagicLinksPerHour = 5
+const maxMagicLinksPerIPPerHour = 20
+
+// same response whether or not the account exists
+const magicLinkMessage = "if an account exists for this email, a sign in link has been sent"
+
+// Start a passwordless sign in by emailing a one time link
+func RequestMagicLink(c *gin.Context) {
+	requestID := requestid.Get(c)
+	var req models.MagicLinkRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Warn(requestID, "failed to parse sign in link request", err.Error())
+		utils.SetResponse(c, requestID, nil, "email required", true, http.StatusBadRequest)
+		return
+	}
+
+	//locked accounts and ips do not get links either
+	if rejectThrottledLogin(c, requestID, req.Email) {
+		return
+	}
+
+	//every request counts, links are only stored for known accounts
+	requests, err := dao.CountAttempt(utils.MagicLinkAttemptKey(c.ClientIP()), time.Hour)
+	if err != nil {
+		logger.Error(requestID, "could not count sign in link requests", err.Error())
+		utils.SetResponse(c, requestID, nil, "failed to send sign in link", true, http.StatusBadRequest)
+		return
+	}
+	if requests > maxMagicLinksPerIPPerHour {
+		logger.Warn(requestID, "sign in links throttled for ip", "", "ip: "+c.ClientIP())
+		utils.SetResponse(c, requestID, nil, "too many sign in links requested, please try again later", true, http.StatusTooManyRequests)
+		return
+	}
+
+	nonce, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate nonce", err.Error())
+		utils.SetResponse(c, requestID, nil, "failed to send sign in link", true, http.StatusInternalServerError)
+		return
+	}
+
+	//the cookie is set for unknown emails too so the response does not tell them apart
+	ttl := utils.DurationFromEnv("MAGIC_LINK_TTL", 15*time.Minute)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(magicLinkCookie, nonce, int(ttl.Seconds()), "/user/signin/magic", "", strings.HasPrefix(utils.AppURL(), "https://"), true)
+
+	login, err := dao.GetLoginByEmail(req.Email)
+	if err != nil {
+		logger.Warn(requestID, "sign in link requested for unknown email", err.Error())
+		utils.SetResponse(c, requestID, nil, magicLinkMessage, false, http.StatusOK)
+		return
+	}
+
+	count, lastSent, err := dao.GetRecentMagicLinks(login.UserID, time.Now().Add(-time.Hour))
+	if err != nil {
+		logger.Error(requestID, "could not fetch sign in link history", err.Error(), "userID: "+strconv.Itoa(int(login.UserID)))
+		utils.SetResponse(c, requestID, nil, magicLinkMessage, false, http.StatusOK)
+		return
+	}
+
+	//throttled silently per account so the limit does not reveal the account exists
+	interval := utils.DurationFromEnv("MAGIC_LINK_RESEND_INTERVAL", time.Minute)
+	if count >= maxMagicLinksPerHour || (lastSent != nil && time.Since(*lastSent) < interval) {
+		logger.Warn(requestID, "sign in links throttled for user", "", "userID: "+strconv.Itoa(int(login.UserID)))
+		utils.SetResponse(c, requestID, nil, magicLinkMessage, false, http.StatusOK)
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate sign in link", err.Error(), "userID: "+strconv.Itoa(int(login.UserID)))
+		utils.SetResponse(c, requestID, nil, magicLinkMessage, false, http.StatusOK)
+		return
+	}
+
+	err = dao.SaveMagicLink(&dao.MagicLink{
+		TokenHash: utils.HashToken(token),
+		NonceHash: utils.HashToken(nonce),
+		ExpiresAt: time.Now().Add(ttl),
+		IP:        c.ClientIP(),
+		UserID:    login.UserID,
+	})
+	if err != nil {
+		logger.Error(requestID, "failed to save sign in link", err.Error(), "userID: "+strconv.Itoa(int(login.UserID)))
+		utils.SetResponse(c, requestID, nil, magicLinkMessage, false, http.StatusOK)
+		return
+	}
+
+	link := utils.AppURL() + "/user/signin/magic/verify?token=" + token
+	if wantsCookieSession(c) {
+		link += "&session_mode=cookie"
+	}
+	utils.SendMailAsync(requestID, login.Email, "Your sign in link",
+		"Use the link below to sign in. It expires in "+ttl.String()+", can be used once and only works in the browser where it was requested.\n\n"+link+
+			"\n\nIf you did not request a sign in link, you can ignore this email.")
+
+	logger.Info(requestID, "sign in link sent", "userID: "+strconv.Itoa(int(login.UserID)))
+	utils.SetResponse(c, requestID, nil, magicLinkMessage, false, http.StatusOK)
+}
+
+// Complete a passwordless sign in by exchanging the link for the token pair
+func VerifyMagicLink(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	token := c.Query("token")
+	nonce, err := c.Cookie(magicLinkCookie)
+	if token == "" || err != nil || nonce == "" {
+		logger.Warn(requestID, "sign in link opened without its browser nonce", "")
+		utils.SetResponse(c, requestID, nil, dao.ErrMagicLinkBrowserMismatch.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	uid, err := dao.ConsumeMagicLink(utils.HashToken(token), utils.HashToken(nonce))
+	if errors.Is(err, dao.ErrInvalidMagicLink) || errors.Is(err, dao.ErrMagicLinkBrowserMismatch) {
+		logger.Warn(requestID, "sign in link rejected", err.Error())
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to consume sign in link", err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+	c.SetCookie(magicLinkCookie, "", -1, "/user/signin/magic", "", strings.HasPrefix(utils.AppURL(), "https://"), true)
+
+	user, err := dao.GetUserById(uid)
+	if err != nil {
+		logger.Error(requestID, "could not fetch user", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	if rejectThrottledLogin(c, requestID, user.Email) {
+		return
+	}
+
+	if err := dao.SaveAuditLog("magic_link_sign_in", uid, c.ClientIP(), ""); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+	}
+
+	scopes := utils.SessionScopes(false)
+
+	//the link replaces the password, not the second factor
+	twoFactorEnabled, err := dao.IsTwoFactorEnabled(uid)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch two factor settings", "userID: "+strconv.Itoa(int(uid)), err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	if twoFactorEnabled {
+		challengeToken, err := utils.GenerateChallengeToken(uid, scopes)
+		if err != nil {
+			logger.Error(requestID, "failed to generate challenge token", "userID: "+strconv.Itoa(int(uid)), err.Error())
+			utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+			return
+		}
+
+		logger.Info(requestID, "two factor challenge issued", "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, gin.H{"two_factor_required": true, "challenge_token": challengeToken}, "two factor code required", false, http.StatusOK)
+		return
+	}
+
+	recordLoginSuccess(requestID, user.Email)
+
+	//generating token
+	userToken, refreshToken, err := utils.GenerateTokens(uid, scopes)
+	if err != nil {
+		logger.Error(requestID, "failed to generate tokens", "userID: "+strconv.Itoa(int(uid)), err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	//save pair of token
+	err = dao.SaveToken(uid, userToken, refreshToken, sessionInfo(c))
+	if err != nil {
+		logger.Error(requestID, "failed to save tokens", "userID: "+strconv.Itoa(int(uid)), err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	data, err := sessionTokens(c, wantsCookieSession(c), userToken, refreshToken)
+	if err != nil {
+		logger.Error(requestID, "failed to set session cookies", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "user signed in with sign in link", "userID: "+strconv.Itoa(int(uid)))
+	utils.SetResponse(c, requestID, data, "user sign in successfully", false, http.StatusCreated)
+}
@@ -0,0 +1,280 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Consent screen data for an authorization request
+func GetAuthorization(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var req models.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error(requestID, "Invalid authorization request", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "invalid authorization request", true, http.StatusBadRequest)
+		return
+	}
+
+	client, scopes, err := validateAuthorizeRequest(&req)
+	if err != nil {
+		logger.Warn(requestID, "Invalid authorization request", err.Error(), "userID: "+strconv.Itoa(int(userId)), "clientID: "+req.ClientID)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	consent, err := dao.GetConsent(userId, client.ClientID)
+	if err != nil {
+		logger.Error(requestID, "could not fetch consent", err.Error(), "userID: "+strconv.Itoa(int(userId)), "clientID: "+client.ClientID)
+		utils.SetResponse(c, requestID, nil, "could not fetch consent", true, http.StatusBadRequest)
+		return
+	}
+
+	res := models.ConsentResponse{
+		ClientName:     client.Name,
+		ClientID:       client.ClientID,
+		RedirectURI:    req.RedirectURI,
+		Scopes:         scopes,
+		State:          req.State,
+		AlreadyGranted: consent != nil && len(utils.MissingScopes(strings.Fields(consent.Scopes), scopes)) == 0,
+	}
+
+	logger.Info(requestID, "Authorization request validated", "userID: "+strconv.Itoa(int(userId)), "clientID: "+client.ClientID)
+	utils.SetResponse(c, requestID, res, "authorization request validated", false, http.StatusOK)
+}
+
+// Records the decision of the user on the consent screen and returns where to redirect
+func Authorize(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var req models.AuthorizeDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "invalid request body", true, http.StatusBadRequest)
+		return
+	}
+
+	//nothing is sent to the redirect uri before it is validated
+	client, scopes, err := validateAuthorizeRequest(&req.AuthorizeRequest)
+	if err != nil {
+		logger.Warn(requestID, "Invalid authorization request", err.Error(), "userID: "+strconv.Itoa(int(userId)), "clientID: "+req.ClientID)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	if !req.Approve {
+		logger.Info(requestID, "Authorization denied by user", "userID: "+strconv.Itoa(int(userId)), "clientID: "+client.ClientID)
+		utils.SetResponse(c, requestID, gin.H{"redirect_to": redirectWith(req.RedirectURI, "error", "access_denied", req.State)}, "authorization denied", false, http.StatusOK)
+		return
+	}
+
+	code, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate authorization code", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not authorize client", true, http.StatusInternalServerError)
+		return
+	}
+
+	familyID, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate token family", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not authorize client", true, http.StatusInternalServerError)
+		return
+	}
+
+	if err := dao.SaveConsent(userId, client.ClientID, scopes); err != nil {
+		logger.Error(requestID, "failed to save consent", err.Error(), "userID: "+strconv.Itoa(int(userId)), "clientID: "+client.ClientID)
+		utils.SetResponse(c, requestID, nil, "could not authorize client", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.SaveAuthorizationCode(&dao.AuthorizationCode{
+		CodeHash:      utils.HashToken(code),
+		ClientID:      client.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        strings.Join(scopes, " "),
+		CodeChallenge: req.CodeChallenge,
+		FamilyID:      familyID,
+		ExpiresAt:     time.Now().Add(utils.DurationFromEnv("OAUTH_CODE_TTL", time.Minute)),
+		UserID:        userId,
+	})
+	if err != nil {
+		logger.Error(requestID, "failed to save authorization code", err.Error(), "userID: "+strconv.Itoa(int(userId)), "clientID: "+client.ClientID)
+		utils.SetResponse(c, requestID, nil, "could not authorize client", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.SaveAuditLog("oauth_consent_granted", userId, c.ClientIP(), "client: "+client.ClientID+", scopes: "+strings.Join(scopes, " ")); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+	}
+
+	logger.Info(requestID, "Client authorized successfully", "userID: "+strconv.Itoa(int(userId)), "clientID: "+client.ClientID)
+	utils.SetResponse(c, requestID, gin.H{"redirect_to": redirectWith(req.RedirectURI, "code", code, req.State)}, "client authorized successfully", false, http.StatusOK)
+}
+
+// List the apps user has granted access to
+func GetConsents(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	consents, err := dao.GetConsents(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch consents", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch consents", true, http.StatusBadRequest)
+		return
+	}
+
+	res := make([]models.GrantedConsentResponse, 0, len(consents))
+	for _, consent := range consents {
+		granted := models.GrantedConsentResponse{
+			ClientID:  consent.ClientID,
+			Scopes:    strings.Fields(consent.Scopes),
+			GrantedAt: consent.UpdatedAt,
+		}
+		if client, err := dao.GetClient(consent.ClientID); err == nil {
+			granted.ClientName = client.Name
+		}
+		res = append(res, granted)
+	}
+
+	logger.Info(requestID, "Consents fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, res, "consents fetched successfully", false, http.StatusOK)
+}
+
+// Withdraw the access of an app and revoke its tokens
+func RevokeConsent(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	clientId := c.Param("client_id")
+	err = dao.RevokeConsent(userId, clientId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Warn(requestID, "consent not found", err.Error(), "userID: "+strconv.Itoa(int(userId)), "clientID: "+clientId)
+		utils.SetResponse(c, requestID, nil, "consent not found", true, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to revoke consent", err.Error(), "userID: "+strconv.Itoa(int(userId)), "clientID: "+clientId)
+		utils.SetResponse(c, requestID, nil, "failed to revoke consent", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.SaveAuditLog("oauth_consent_revoked", userId, c.ClientIP(), "client: "+clientId); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+	}
+
+	logger.Info(requestID, "Consent revoked successfully", "userID: "+strconv.Itoa(int(userId)), "clientID: "+clientId)
+	utils.SetResponse(c, requestID, nil, "consent revoked successfully", false, http.StatusOK)
+}
+
+// checks an authorization request, returns the client and the scopes asked for
+func validateAuthorizeRequest(req *models.AuthorizeRequest) (*dao.Client, []string, error) {
+	client, err := dao.GetClient(req.ClientID)
+	if err != nil {
+		return nil, nil, errors.New("unknown client")
+	}
+
+	if !hasField(client.GrantTypes, utils.GrantAuthorizationCode) {
+		return nil, nil, errors.New("client is not allowed to use the authorization code grant")
+	}
+
+	//the redirect uri can be left out when only one is registered
+	redirectURIs := strings.Fields(client.RedirectURIs)
+	if req.RedirectURI == "" && len(redirectURIs) == 1 {
+		req.RedirectURI = redirectURIs[0]
+	}
+	if !hasField(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, errors.New("redirect uri is not registered for this client")
+	}
+
+	if req.ResponseType != "code" {
+		return nil, nil, errors.New("response_type must be code")
+	}
+
+	//PKCE is required for every client (RFC 7636)
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) < 43 || len(req.CodeChallenge) > 128 {
+		return nil, nil, errors.New("a code_challenge with code_challenge_method S256 is required")
+	}
+
+	scopes, err := utils.ResolveRequestedScopes(req.Scope, strings.Fields(client.Scopes))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, scopes, nil
+}
+
+// redirect uri with a code or error and the state of the request
+func redirectWith(redirectURI, key, value, state string) string {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := parsed.Query()
+	query.Set(key, value)
+	if state != "" {
+		query.Set("state", state)
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+// checks whether a space separated list contains a value
+func hasField(fields, value string) bool {
+	for _, f := range strings.Fields(fields) {
+		if f == value {
+			return true
+		}
+	}
+
+	return false
+}
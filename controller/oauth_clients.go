@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Register an OAuth client, the secret is only shown in this response
+func RegisterClient(c *gin.Context) {
+	requestID := requestid.Get(c)
+	var req models.RegisterClientRequest
+
+	bodyBytes, _ := io.ReadAll(c.Request.Body)
+	requestBody := string(bodyBytes)
+
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "invalid request body", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := utils.ValidateClientRegistration(&req); err != nil {
+		logger.Error(requestID, "Invalid client registration", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	clientId, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate client id", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not register client", true, http.StatusInternalServerError)
+		return
+	}
+
+	client := dao.Client{
+		ClientID:     clientId,
+		Name:         req.Name,
+		RedirectURIs: strings.Join(req.RedirectURIs, " "),
+		GrantTypes:   strings.Join(req.GrantTypes, " "),
+		Scopes:       strings.Join(req.Scopes, " "),
+		UserID:       userId,
+	}
+
+	secret := ""
+	if !req.Public {
+		secret, err = utils.GenerateRandomToken()
+		if err != nil {
+			logger.Error(requestID, "failed to generate client secret", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+			utils.SetResponse(c, requestID, nil, "could not register client", true, http.StatusInternalServerError)
+			return
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if err := dao.SaveClient(&client); err != nil {
+		logger.Error(requestID, "failed to save client", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not register client", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.SaveAuditLog("oauth_client_registered", userId, c.ClientIP(), "client: "+client.ClientID); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+	}
+
+	res := clientResponse(client)
+	res.ClientSecret = secret
+
+	logger.Info(requestID, "OAuth client registered successfully", "userID: "+strconv.Itoa(int(userId)), "clientID: "+client.ClientID)
+	utils.SetResponse(c, requestID, res, "client registered successfully, the secret will not be shown again", false, http.StatusCreated)
+}
+
+// List the OAuth clients registered by user
+func GetClients(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	clients, err := dao.GetClients(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch clients", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch clients", true, http.StatusBadRequest)
+		return
+	}
+
+	res := make([]models.ClientResponse, 0, len(clients))
+	for _, client := range clients {
+		res = append(res, clientResponse(client))
+	}
+
+	logger.Info(requestID, "OAuth clients fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, res, "clients fetched successfully", false, http.StatusOK)
+}
+
+// Delete an OAuth client of user, revoking every token issued to it
+func DeleteClient(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(requestID, "failed to parse client id", c.Param("id"), err.Error())
+		utils.SetResponse(c, requestID, nil, "could not parse client id", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.DeleteClient(userId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Warn(requestID, "client not found", err.Error(), "userID: "+strconv.Itoa(int(userId)), "ID: "+strconv.Itoa(int(id)))
+		utils.SetResponse(c, requestID, nil, "client not found", true, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to delete client", err.Error(), "userID: "+strconv.Itoa(int(userId)), "ID: "+strconv.Itoa(int(id)))
+		utils.SetResponse(c, requestID, nil, "failed to delete client", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "OAuth client deleted successfully", "userID: "+strconv.Itoa(int(userId)), "ID: "+strconv.Itoa(int(id)))
+	utils.SetResponse(c, requestID, nil, "client deleted successfully", false, http.StatusOK)
+}
+
+func clientResponse(client dao.Client) models.ClientResponse {
+	return models.ClientResponse{
+		ID:           client.ID,
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: strings.Fields(client.RedirectURIs),
+		GrantTypes:   strings.Fields(client.GrantTypes),
+		Scopes:       strings.Fields(client.Scopes),
+		Public:       client.SecretHash == "",
+		CreatedAt:    client.CreatedAt,
+	}
+}
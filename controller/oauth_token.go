@@ -0,0 +1,374 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// OAuth token endpoint for the authorization code, refresh token and client credentials grants
+func IssueToken(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	client, err := authenticateClient(c)
+	if err != nil {
+		logger.Warn(requestID, "client authentication failed", err.Error(), "clientID: "+c.PostForm("client_id"))
+		oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	//refresh tokens are only issued for the authorization code grant
+	grant := c.PostForm("grant_type")
+	allowed := grant
+	if grant == utils.GrantRefreshToken {
+		allowed = utils.GrantAuthorizationCode
+	}
+	if !hasField(client.GrantTypes, allowed) {
+		logger.Warn(requestID, "grant type not allowed for client", grant, "clientID: "+client.ClientID)
+		oauthError(c, http.StatusBadRequest, "unauthorized_client", "client is not allowed to use the "+grant+" grant")
+		return
+	}
+
+	switch grant {
+	case utils.GrantAuthorizationCode:
+		issueTokenForCode(c, requestID, client)
+	case utils.GrantRefreshToken:
+		refreshClientToken(c, requestID, client)
+	case utils.GrantClientCredentials:
+		issueClientCredentialsToken(c, requestID, client)
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant type")
+	}
+}
+
+// Exchange an authorization code for a token pair, checking the PKCE code verifier
+func issueTokenForCode(c *gin.Context, requestID string, client *dao.Client) {
+	//a malformed verifier is rejected before the code is used up
+	verifier := c.PostForm("code_verifier")
+	if err := utils.ValidateCodeVerifier(verifier); err != nil {
+		logger.Warn(requestID, "invalid code verifier", err.Error(), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	code, err := dao.ConsumeAuthorizationCode(utils.HashToken(c.PostForm("code")))
+	if errors.Is(err, dao.ErrAuthorizationCodeReused) {
+		//tokens issued for a replayed code are revoked (RFC 6749 section 4.1.2)
+		revoked, err := dao.RevokeTokenFamily(code.FamilyID)
+		if err != nil {
+			logger.Error(requestID, "could not revoke tokens of reused code", err.Error(), "clientID: "+client.ClientID)
+		}
+
+		logger.Warn(requestID, "authorization code reuse detected", "userID: "+strconv.Itoa(int(code.UserID)), "clientID: "+client.ClientID)
+		if err := dao.SaveAuditLog("oauth_code_reuse", code.UserID, c.ClientIP(), "client: "+client.ClientID+", "+strconv.Itoa(int(revoked))+" sessions revoked"); err != nil {
+			logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(code.UserID)))
+		}
+
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "authorization code was already used")
+		return
+	}
+	if errors.Is(err, dao.ErrInvalidAuthorizationCode) {
+		logger.Warn(requestID, "invalid authorization code", err.Error(), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to consume authorization code", err.Error(), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not issue token")
+		return
+	}
+
+	if code.ClientID != client.ClientID {
+		logger.Warn(requestID, "authorization code issued to another client", "clientID: "+client.ClientID)
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "authorization code was issued to another client")
+		return
+	}
+
+	if redirectURI := c.PostForm("redirect_uri"); redirectURI != "" && redirectURI != code.RedirectURI {
+		logger.Warn(requestID, "redirect uri does not match authorization request", "clientID: "+client.ClientID)
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "redirect uri does not match the authorization request")
+		return
+	}
+
+	if !utils.VerifyCodeChallenge(verifier, code.CodeChallenge) {
+		logger.Warn(requestID, "code verifier does not match", "clientID: "+client.ClientID)
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "code verifier does not match the code challenge")
+		return
+	}
+
+	issueClientTokens(c, requestID, client, code.UserID, strings.Fields(code.Scopes), code.FamilyID, true)
+}
+
+// Rotate the refresh token of a client, a rotated token presented again revokes its family
+func refreshClientToken(c *gin.Context, requestID string, client *dao.Client) {
+	refreshToken := c.PostForm("refresh_token")
+
+	claims, err := utils.VerifyRefreshToken(refreshToken)
+	if err != nil || claims.ClientID != client.ClientID {
+		logger.Warn(requestID, "invalid refresh token", "clientID: "+client.ClientID)
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	reused, err := revokeReusedRefreshToken(c, refreshToken)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not refresh token")
+		return
+	}
+	if reused {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	session, err := dao.GetSessionByRefreshToken(refreshToken)
+	if err != nil {
+		logger.Warn(requestID, "session of refresh token not found", err.Error(), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	//a refresh can narrow the scopes but never widen them
+	scopes, err := utils.ResolveRequestedScopes(c.PostForm("scope"), claims.Scopes)
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+
+	newUserToken, newRefreshToken, err := utils.GenerateClientTokens(claims.UserID, client.ClientID, scopes)
+	if err != nil {
+		logger.Error(requestID, "failed to generate tokens", err.Error(), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not refresh token")
+		return
+	}
+
+	info := dao.SessionInfoOf(session)
+	info.IP = c.ClientIP()
+
+	rotated, err := dao.RotateRefreshToken(session, newUserToken, newRefreshToken, info, utils.DurationFromEnv("REF_EXP_DURATION", 4*time.Hour))
+	if err != nil {
+		logger.Error(requestID, "could not rotate refresh token", err.Error(), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not refresh token")
+		return
+	}
+	if !rotated {
+		revokeReusedRefreshToken(c, refreshToken)
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	logger.Info(requestID, "client token refreshed successfully", "userID: "+strconv.Itoa(int(claims.UserID)), "clientID: "+client.ClientID)
+	tokenResponse(c, newUserToken, newRefreshToken, scopes)
+}
+
+// Issue an access token to a confidential client acting for the user that registered it
+func issueClientCredentialsToken(c *gin.Context, requestID string, client *dao.Client) {
+	if client.SecretHash == "" {
+		oauthError(c, http.StatusBadRequest, "unauthorized_client", "public clients cannot use the client_credentials grant")
+		return
+	}
+
+	scopes, err := utils.ResolveRequestedScopes(c.PostForm("scope"), strings.Fields(client.Scopes))
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+
+	//no refresh token is returned for client credentials (RFC 6749 section 4.4.3)
+	issueClientTokens(c, requestID, client, client.UserID, scopes, "", false)
+}
+
+// Generate and save a token pair of a client
+func issueClientTokens(c *gin.Context, requestID string, client *dao.Client, uid int64, scopes []string, familyID string, withRefresh bool) {
+	userToken, refreshToken, err := utils.GenerateClientTokens(uid, client.ClientID, scopes)
+	if err != nil {
+		logger.Error(requestID, "failed to generate tokens", err.Error(), "userID: "+strconv.Itoa(int(uid)), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not issue token")
+		return
+	}
+
+	session := models.SessionInfo{
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		DeviceName: client.Name,
+		FamilyID:   familyID,
+		ClientID:   client.ClientID,
+	}
+	if err := dao.SaveToken(uid, userToken, refreshToken, session); err != nil {
+		logger.Error(requestID, "failed to save tokens", err.Error(), "userID: "+strconv.Itoa(int(uid)), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not issue token")
+		return
+	}
+
+	if !withRefresh {
+		refreshToken = ""
+	}
+
+	logger.Info(requestID, "token issued to client", "userID: "+strconv.Itoa(int(uid)), "clientID: "+client.ClientID)
+	tokenResponse(c, userToken, refreshToken, scopes)
+}
+
+// Token introspection for the client the token was issued to (RFC 7662)
+func IntrospectToken(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	client, err := authenticateClient(c)
+	if err == nil && client.SecretHash == "" {
+		err = errors.New("public clients cannot introspect tokens")
+	}
+	if err != nil {
+		logger.Warn(requestID, "client authentication failed", err.Error(), "clientID: "+c.PostForm("client_id"))
+		oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	//the hint only decides which token type is tried first
+	kinds := []bool{false, true}
+	if c.PostForm("token_type_hint") == "refresh_token" {
+		kinds = []bool{true, false}
+	}
+
+	c.Header("Cache-Control", "no-store")
+	for _, refresh := range kinds {
+		var claims *models.TokenClaims
+		if refresh {
+			claims, err = utils.VerifyRefreshToken(token)
+		} else {
+			claims, err = utils.VerifyJwtToken(token)
+		}
+		if err != nil || claims.ClientID != client.ClientID {
+			continue
+		}
+
+		active, err := dao.IsClientTokenActive(client.ClientID, utils.HashToken(token), refresh)
+		if err != nil {
+			logger.Error(requestID, "could not introspect token", err.Error(), "clientID: "+client.ClientID)
+			oauthError(c, http.StatusInternalServerError, "server_error", "could not introspect token")
+			return
+		}
+		if !active {
+			continue
+		}
+
+		res := models.IntrospectionResponse{
+			Active:   true,
+			Scope:    strings.Join(claims.Scopes, " "),
+			ClientID: claims.ClientID,
+			Sub:      strconv.FormatInt(claims.UserID, 10),
+			Exp:      claims.ExpiresAt.Unix(),
+			Iat:      claims.IssuedAt.Unix(),
+		}
+		if !refresh {
+			res.TokenType = "Bearer"
+		}
+
+		logger.Info(requestID, "token introspected", "active", "clientID: "+client.ClientID)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	logger.Info(requestID, "token introspected", "inactive", "clientID: "+client.ClientID)
+	c.JSON(http.StatusOK, models.IntrospectionResponse{Active: false})
+}
+
+// Token revocation for the client the token was issued to (RFC 7009)
+func RevokeToken(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	client, err := authenticateClient(c)
+	if err != nil {
+		logger.Warn(requestID, "client authentication failed", err.Error(), "clientID: "+c.PostForm("client_id"))
+		oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	//revoking either token of a pair revokes both
+	if err := dao.RevokeClientToken(client.ClientID, utils.HashToken(token)); err != nil {
+		logger.Error(requestID, "could not revoke token", err.Error(), "clientID: "+client.ClientID)
+		oauthError(c, http.StatusServiceUnavailable, "server_error", "could not revoke token")
+		return
+	}
+
+	//unknown or already revoked tokens are not an error (RFC 7009 section 2.2)
+	logger.Info(requestID, "token revoked by client", "clientID: "+client.ClientID)
+	c.Status(http.StatusOK)
+}
+
+// Authenticates the client with HTTP basic or form credentials, public clients send only their id
+func authenticateClient(c *gin.Context) (*dao.Client, error) {
+	clientId, secret, basic := c.Request.BasicAuth()
+	if basic {
+		//basic credentials are form-urlencoded (RFC 6749 section 2.3.1)
+		clientId, _ = url.QueryUnescape(clientId)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientId = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+
+	if clientId == "" {
+		return nil, errors.New("client authentication required")
+	}
+
+	client, err := dao.GetClient(clientId)
+	if err != nil {
+		return nil, errors.New("unknown client")
+	}
+
+	if client.SecretHash == "" {
+		if secret != "" {
+			return nil, errors.New("public clients have no secret")
+		}
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, errors.New("invalid client credentials")
+	}
+
+	return client, nil
+}
+
+// Successful token response (RFC 6749 section 5.1)
+func tokenResponse(c *gin.Context, userToken, refreshToken string, scopes []string) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, models.OAuthTokenResponse{
+		AccessToken:  userToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(utils.DurationFromEnv("JWT_EXP_DURATION", 2*time.Hour).Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	})
+}
+
+// Error response (RFC 6749 section 5.2)
+func oauthError(c *gin.Context, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
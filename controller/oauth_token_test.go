@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/utils"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// point dao at an empty in memory database and silence the logger
+func useTestDB(t *testing.T, tables ...interface{}) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+
+	previousDB, previousLogger := dao.DB, logger.Logger
+	dao.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() { dao.DB, logger.Logger = previousDB, previousLogger })
+}
+
+func TestIssueTokenForCode(t *testing.T) {
+	useTestDB(t, &dao.User{}, &dao.Token{}, &dao.Client{}, &dao.AuthorizationCode{})
+	t.Setenv("JWT_SEC", "test-secret")
+	t.Setenv("JWT_REF_SEC", "test-refresh-secret")
+	gin.SetMode(gin.TestMode)
+
+	user := dao.User{Name: "Jane", Email: "jane@example.com"}
+	if err := dao.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := dao.Client{ClientID: "client-1", Name: "Client", GrantTypes: utils.GrantAuthorizationCode, Scopes: "tasks:read", UserID: user.ID}
+	if err := dao.DB.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	codes := 0
+	newCode := func() string {
+		codes++
+		code := "code-" + strconv.Itoa(codes)
+		err := dao.DB.Create(&dao.AuthorizationCode{
+			CodeHash:      utils.HashToken(code),
+			ClientID:      client.ClientID,
+			RedirectURI:   "https://client.example/callback",
+			Scopes:        "tasks:read",
+			CodeChallenge: challenge,
+			FamilyID:      "family-" + code,
+			ExpiresAt:     time.Now().Add(time.Minute),
+			UserID:        user.ID,
+		}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	exchange := func(code, verifier string) (int, map[string]interface{}) {
+		form := url.Values{"grant_type": {utils.GrantAuthorizationCode}, "client_id": {client.ClientID}, "code": {code}}
+		if verifier != "" {
+			form.Set("code_verifier", verifier)
+		}
+
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		IssueToken(c)
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return recorder.Code, body
+	}
+
+	tests := []struct {
+		name     string
+		verifier string
+		// status and error of the exchange, 200 when tokens are issued
+		wantStatus int
+		wantError  string
+		// whether the code can still be exchanged afterwards
+		wantCodeLeft bool
+	}{
+		{name: "s256 verifier", verifier: verifier, wantStatus: http.StatusOK},
+		{name: "other verifier", verifier: strings.Repeat("a", 43), wantStatus: http.StatusBadRequest, wantError: "invalid_grant"},
+		{name: "missing verifier", wantStatus: http.StatusBadRequest, wantError: "invalid_request", wantCodeLeft: true},
+		{name: "too short", verifier: verifier[:42], wantStatus: http.StatusBadRequest, wantError: "invalid_request", wantCodeLeft: true},
+		{name: "too long", verifier: strings.Repeat(verifier, 3), wantStatus: http.StatusBadRequest, wantError: "invalid_request", wantCodeLeft: true},
+		{name: "reserved characters", verifier: verifier[:42] + "=", wantStatus: http.StatusBadRequest, wantError: "invalid_request", wantCodeLeft: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := newCode()
+
+			status, body := exchange(code, tt.verifier)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", status, tt.wantStatus, body)
+			}
+			if tt.wantError != "" && body["error"] != tt.wantError {
+				t.Errorf("error = %v, want %s", body["error"], tt.wantError)
+			}
+			if tt.wantStatus == http.StatusOK && (body["access_token"] == nil || body["refresh_token"] == nil) {
+				t.Errorf("token response without tokens: %v", body)
+			}
+
+			//a used code is rejected, one rejected before use still works
+			status, body = exchange(code, verifier)
+			if tt.wantCodeLeft && status != http.StatusOK {
+				t.Errorf("code was used up: %d %v", status, body)
+			}
+			if !tt.wantCodeLeft && (status != http.StatusBadRequest || body["error"] != "invalid_grant") {
+				t.Errorf("used code exchanged again: %d %v", status, body)
+			}
+		})
+	}
+}
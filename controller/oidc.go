@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// cookie binding a sign in with an external provider to the browser that started it
+const oidcStateCookie = "oidc_state"
+
+// Start a sign in with an external provider using authorization code + PKCE
+func OIDCLogin(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	provider, err := utils.GetOIDCProvider(c.Param("provider"))
+	if err != nil {
+		logger.Warn(requestID, "unknown identity provider", err.Error(), c.Param("provider"))
+		utils.SetResponse(c, requestID, nil, "unknown identity provider", true, http.StatusNotFound)
+		return
+	}
+
+	state, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate state", err.Error())
+		utils.SetResponse(c, requestID, nil, "could not start sign in", true, http.StatusInternalServerError)
+		return
+	}
+
+	nonce, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate nonce", err.Error())
+		utils.SetResponse(c, requestID, nil, "could not start sign in", true, http.StatusInternalServerError)
+		return
+	}
+
+	verifier, challenge, err := utils.GeneratePKCE()
+	if err != nil {
+		logger.Error(requestID, "failed to generate code verifier", err.Error())
+		utils.SetResponse(c, requestID, nil, "could not start sign in", true, http.StatusInternalServerError)
+		return
+	}
+
+	authorizationURL, err := provider.AuthorizationURL(state, nonce, challenge)
+	if err != nil {
+		logger.Error(requestID, "failed to reach identity provider", err.Error(), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "identity provider unavailable", true, http.StatusBadGateway)
+		return
+	}
+
+	encryptedVerifier, err := utils.EncryptSecret(verifier)
+	if err != nil {
+		logger.Error(requestID, "failed to encrypt code verifier", err.Error())
+		utils.SetResponse(c, requestID, nil, "could not start sign in", true, http.StatusInternalServerError)
+		return
+	}
+
+	ttl := utils.DurationFromEnv("OIDC_STATE_TTL", 10*time.Minute)
+	err = dao.SaveOIDCState(&dao.OIDCState{
+		StateHash:       utils.HashToken(state),
+		Provider:        provider.Name,
+		Nonce:           nonce,
+		CodeVerifierEnc: encryptedVerifier,
+		CookieMode:      wantsCookieSession(c),
+		ExpiresAt:       time.Now().Add(ttl),
+	})
+	if err != nil {
+		logger.Error(requestID, "failed to save sign in state", err.Error(), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "could not start sign in", true, http.StatusBadRequest)
+		return
+	}
+
+	//lax so the cookie comes back on the redirect from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(ttl.Seconds()), "/user/oidc", "", strings.HasPrefix(utils.AppURL(), "https://"), true)
+
+	logger.Info(requestID, "external sign in started", "provider: "+provider.Name)
+	utils.SetResponse(c, requestID, gin.H{"authorization_url": authorizationURL}, "redirect to the identity provider", false, http.StatusOK)
+}
+
+// Complete a sign in with an external provider and issue the token pair
+func OIDCCallback(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	provider, err := utils.GetOIDCProvider(c.Param("provider"))
+	if err != nil {
+		logger.Warn(requestID, "unknown identity provider", err.Error(), c.Param("provider"))
+		utils.SetResponse(c, requestID, nil, "unknown identity provider", true, http.StatusNotFound)
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		logger.Warn(requestID, "identity provider returned an error", providerError, c.Query("error_description"), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "sign in with "+provider.Name+" failed: "+providerError, true, http.StatusBadRequest)
+		return
+	}
+
+	//the state must come back to the same browser that started the sign in
+	state := c.Query("state")
+	cookieState, err := c.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		logger.Warn(requestID, "sign in state does not match the browser", "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "invalid or expired sign in state", true, http.StatusBadRequest)
+		return
+	}
+	c.SetCookie(oidcStateCookie, "", -1, "/user/oidc", "", strings.HasPrefix(utils.AppURL(), "https://"), true)
+
+	pending, err := dao.ConsumeOIDCState(utils.HashToken(state), provider.Name)
+	if err != nil {
+		logger.Warn(requestID, "invalid sign in state", err.Error(), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "invalid or expired sign in state", true, http.StatusBadRequest)
+		return
+	}
+
+	verifier, err := utils.DecryptSecret(pending.CodeVerifierEnc)
+	if err != nil {
+		logger.Error(requestID, "failed to decrypt code verifier", err.Error(), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusInternalServerError)
+		return
+	}
+
+	claims, err := provider.Exchange(c.Query("code"), verifier, pending.Nonce)
+	if err != nil {
+		logger.Warn(requestID, "external sign in could not be verified", err.Error(), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "sign in with "+provider.Name+" could not be verified", true, http.StatusUnauthorized)
+		return
+	}
+
+	uid, outcome, err := dao.SignInExternalIdentity(provider.Name, *claims)
+	if errors.Is(err, dao.ErrUnverifiedExternalEmail) {
+		logger.Warn(requestID, "external account has no verified email", err.Error(), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "a verified email is required to sign in with "+provider.Name, true, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to sign in external account", err.Error(), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	if outcome != dao.ExternalIdentityExisting {
+		if err := dao.SaveAuditLog("external_identity_"+outcome, uid, c.ClientIP(), "provider: "+provider.Name); err != nil {
+			logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		}
+	}
+
+	scopes := utils.SessionScopes(false)
+
+	//two factor still applies to users signing in through a provider
+	twoFactorEnabled, err := dao.IsTwoFactorEnabled(uid)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch two factor settings", "userID: "+strconv.Itoa(int(uid)), err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	if twoFactorEnabled {
+		challengeToken, err := utils.GenerateChallengeToken(uid, scopes)
+		if err != nil {
+			logger.Error(requestID, "failed to generate challenge token", "userID: "+strconv.Itoa(int(uid)), err.Error())
+			utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+			return
+		}
+
+		logger.Info(requestID, "two factor challenge issued", "userID: "+strconv.Itoa(int(uid)), "provider: "+provider.Name)
+		utils.SetResponse(c, requestID, gin.H{"two_factor_required": true, "challenge_token": challengeToken}, "two factor code required", false, http.StatusOK)
+		return
+	}
+
+	//generating token
+	userToken, refreshToken, err := utils.GenerateTokens(uid, scopes)
+	if err != nil {
+		logger.Error(requestID, "failed to generate tokens", "userID: "+strconv.Itoa(int(uid)), err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.SaveToken(uid, userToken, refreshToken, sessionInfo(c))
+	if errors.Is(err, dao.ErrAccountDeletionScheduled) {
+		logger.Warn(requestID, "sign in to account scheduled for deletion", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, gin.H{"deletion_scheduled": true}, "account is scheduled for deletion, restore it with /user/restore to sign in", true, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to save tokens", "userID: "+strconv.Itoa(int(uid)), err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	data, err := sessionTokens(c, pending.CookieMode || wantsCookieSession(c), userToken, refreshToken)
+	if err != nil {
+		logger.Error(requestID, "failed to set session cookies", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+	data["account"] = outcome
+
+	logger.Info(requestID, "user signed in with identity provider", "userID: "+strconv.Itoa(int(uid)), "provider: "+provider.Name, "account: "+outcome)
+	utils.SetResponse(c, requestID, data, "user sign in successfully", false, http.StatusCreated)
+}
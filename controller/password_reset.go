@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// same response whether or not the account exists
+const forgotPasswordMessage = "if an account exists for this email, a password reset link has been sent"
+
+// Start password recovery by emailing a reset link
+func ForgotPassword(c *gin.Context) {
+	requestID := requestid.Get(c)
+	var req models.ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Warn(requestID, "failed to parse forgot password request", err.Error())
+		utils.SetResponse(c, requestID, nil, "email required", true, http.StatusBadRequest)
+		return
+	}
+
+	login, err := dao.GetLoginByEmail(req.Email)
+	if err != nil {
+		logger.Warn(requestID, "password reset requested for unknown email", err.Error())
+		utils.SetResponse(c, requestID, nil, forgotPasswordMessage, false, http.StatusOK)
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate reset token", err.Error(), "userID: "+strconv.Itoa(int(login.UserID)))
+		utils.SetResponse(c, requestID, nil, forgotPasswordMessage, false, http.StatusOK)
+		return
+	}
+
+	ttl := utils.DurationFromEnv("PASSWORD_RESET_TTL", 30*time.Minute)
+	err = dao.SavePasswordReset(login.UserID, utils.HashToken(token), time.Now().Add(ttl))
+	if err != nil {
+		logger.Error(requestID, "failed to save reset token", err.Error(), "userID: "+strconv.Itoa(int(login.UserID)))
+		utils.SetResponse(c, requestID, nil, forgotPasswordMessage, false, http.StatusOK)
+		return
+	}
+
+	link := utils.AppURL() + "/reset-password?token=" + token
+	utils.SendMailAsync(requestID, login.Email, "Reset your password",
+		"Use the link below to reset your password. It expires in "+ttl.String()+" and can be used once.\n\n"+link+
+			"\n\nIf you did not request a password reset, you can ignore this email.")
+
+	logger.Info(requestID, "password reset link sent", "userID: "+strconv.Itoa(int(login.UserID)))
+	utils.SetResponse(c, requestID, nil, forgotPasswordMessage, false, http.StatusOK)
+}
+
+// Reset password with a recovery token, sign out all sessions and revoke personal access tokens
+func ResetPassword(c *gin.Context) {
+	requestID := requestid.Get(c)
+	var req models.ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Warn(requestID, "failed to parse reset password request", err.Error())
+		utils.SetResponse(c, requestID, nil, "token and new password required", true, http.StatusBadRequest)
+		return
+	}
+
+	uid, err := dao.GetPasswordResetUser(utils.HashToken(req.Token))
+	if err != nil {
+		logger.Warn(requestID, "invalid password reset token", err.Error())
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	profile, err := dao.GetUserById(uid)
+	if err != nil {
+		logger.Error(requestID, "could not fetch user", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, nil, "failed to reset password", true, http.StatusBadRequest)
+		return
+	}
+
+	//Validate whether new password is in correct format or not
+	err = utils.ValidateNewPassword(req.NewPassword, profile.Email, profile.Name)
+	if err != nil {
+		logger.Error(requestID, "unable to validate new password", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	policy := utils.GetPasswordPolicy()
+	if rejectReusedPassword(c, requestID, uid, req.NewPassword, policy.HistorySize) {
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		logger.Error(requestID, "failed to hashed password", err.Error())
+		utils.SetResponse(c, requestID, nil, "failed to reset password", true, http.StatusBadRequest)
+		return
+	}
+
+	//consume the token, update password and revoke every session of the user
+	uid, err = dao.ResetPassword(utils.HashToken(req.Token), hashedPassword, policy.HistorySize)
+	if errors.Is(err, dao.ErrInvalidResetToken) {
+		logger.Warn(requestID, "invalid password reset token", err.Error())
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to reset password", err.Error())
+		utils.SetResponse(c, requestID, nil, "failed to reset password", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "Password reset successfully", "userID: "+strconv.Itoa(int(uid)))
+	utils.SetResponse(c, requestID, nil, "password reset successfully", false, http.StatusOK)
+}
+
+// reject the request when the password matches the current or a recent one, returns true if rejected
+func rejectReusedPassword(c *gin.Context, requestID string, uid int64, password string, historySize int) bool {
+	hashes, err := dao.GetPasswordHistory(uid, historySize)
+	if err != nil {
+		logger.Error(requestID, "could not fetch password history", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, nil, "failed to update password", true, http.StatusBadRequest)
+		return true
+	}
+
+	if utils.PasswordInHistory(password, hashes) {
+		logger.Warn(requestID, "password reused", "", "userID: "+strconv.Itoa(int(uid)))
+		utils.SetResponse(c, requestID, nil, "new password must differ from your last "+strconv.Itoa(historySize+1)+" passwords", true, http.StatusBadRequest)
+		return true
+	}
+
+	return false
+}
+
+// describe how a verified password breaks the current policy, empty when it complies
+func passwordPolicyViolation(requestID string, uid int64, email, password string) string {
+	var name string
+	if user, err := dao.GetUserById(uid); err == nil {
+		name = user.Name
+	}
+
+	if err := utils.GetPasswordPolicy().Check(password, email, name); err != nil {
+		logger.Warn(requestID, "password does not meet the password policy", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+		return "password " + err.Error()
+	}
+
+	return ""
+}
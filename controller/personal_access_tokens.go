@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Create a named personal access token, the token is only shown in this response
+func CreatePersonalAccessToken(c *gin.Context) {
+	requestID := requestid.Get(c)
+	var req models.CreatePersonalAccessTokenRequest
+
+	bodyBytes, _ := io.ReadAll(c.Request.Body)
+	requestBody := string(bodyBytes)
+
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	//a personal access token cannot mint further tokens
+	if middlewares.IsPersonalAccessToken(c) {
+		logger.Warn(requestID, "personal access token used to create a token", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "personal access tokens can only be created from a signed in session", true, http.StatusForbidden)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "invalid request body", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := utils.ValidatePersonalAccessToken(&req); err != nil {
+		logger.Error(requestID, "Invalid personal access token", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	token, hint, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		logger.Error(requestID, "failed to generate personal access token", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not create token", true, http.StatusInternalServerError)
+		return
+	}
+
+	pat := dao.PersonalAccessToken{
+		Name:      req.Name,
+		TokenHash: utils.HashToken(token),
+		Hint:      hint,
+		Scopes:    strings.Join(req.Scopes, " "),
+		ExpiresAt: req.ExpiresAt,
+		UserID:    userId,
+	}
+	if err := dao.SavePersonalAccessToken(&pat); err != nil {
+		logger.Error(requestID, "failed to save personal access token", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not create token", true, http.StatusBadRequest)
+		return
+	}
+
+	res := personalAccessTokenResponse(pat)
+	res.Token = token
+
+	logger.Info(requestID, "Personal access token created successfully", "userID: "+strconv.Itoa(int(userId)), "tokenID: "+strconv.Itoa(int(pat.ID)))
+	utils.SetResponse(c, requestID, res, "token created successfully, it will not be shown again", false, http.StatusCreated)
+}
+
+// List the personal access tokens of user
+func GetPersonalAccessTokens(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := dao.GetPersonalAccessTokens(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch personal access tokens", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch tokens", true, http.StatusBadRequest)
+		return
+	}
+
+	res := make([]models.PersonalAccessTokenResponse, 0, len(tokens))
+	for _, t := range tokens {
+		res = append(res, personalAccessTokenResponse(t))
+	}
+
+	logger.Info(requestID, "Personal access tokens fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, res, "tokens fetched successfully", false, http.StatusOK)
+}
+
+// Revoke a personal access token of user
+func DeletePersonalAccessToken(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	tokenId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(requestID, "failed to parse token id", c.Param("id"), err.Error())
+		utils.SetResponse(c, requestID, nil, "could not parse token id", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.DeletePersonalAccessToken(userId, tokenId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Warn(requestID, "personal access token not found", err.Error(), "userID: "+strconv.Itoa(int(userId)), "tokenID: "+strconv.Itoa(int(tokenId)))
+		utils.SetResponse(c, requestID, nil, "token not found", true, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to revoke personal access token", err.Error(), "userID: "+strconv.Itoa(int(userId)), "tokenID: "+strconv.Itoa(int(tokenId)))
+		utils.SetResponse(c, requestID, nil, "failed to revoke token", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "Personal access token revoked successfully", "userID: "+strconv.Itoa(int(userId)), "tokenID: "+strconv.Itoa(int(tokenId)))
+	utils.SetResponse(c, requestID, nil, "token revoked successfully", false, http.StatusOK)
+}
+
+func personalAccessTokenResponse(t dao.PersonalAccessToken) models.PersonalAccessTokenResponse {
+	scopes := []string{}
+	if t.Scopes != "" {
+		scopes = strings.Split(t.Scopes, " ")
+	}
+
+	return models.PersonalAccessTokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Hint:       t.Hint,
+		Scopes:     scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
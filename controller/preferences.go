@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/utils"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// Fetch the user preferences
+func GetPreferences(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := dao.GetPreferences(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch preferences", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch preferences", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "Preferences fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, prefs, "preferences fetched successfully", false, http.StatusOK)
+}
+
+// Updates the user preferences, omitted fields keep their current value
+func UpdatePreferences(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	bodyBytes, _ := io.ReadAll(c.Request.Body)
+	requestBody := string(bodyBytes)
+
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := dao.GetPreferences(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch preferences", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to update preferences", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := c.ShouldBindJSON(prefs); err != nil {
+		logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "invalid request body", true, http.StatusBadRequest)
+		return
+	}
+
+	//validate timezone, week start and other preferences
+	err = utils.ValidatePreferences(*prefs)
+	if err != nil {
+		logger.Error(requestID, "Unable to validate preferences", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.SavePreferences(userId, prefs)
+	if err != nil {
+		logger.Error(requestID, "failed to update preferences", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "failed to update preferences", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "Preferences updated successfully", "userID: "+strconv.Itoa(int(userId)), requestBody)
+	utils.SetResponse(c, requestID, prefs, "preferences updated successfully", false, http.StatusOK)
+}
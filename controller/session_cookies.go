@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"net/http"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Whether the client asked to receive its tokens as cookies instead of in the body
+func wantsCookieSession(c *gin.Context) bool {
+	if !utils.CookieSessionsEnabled() {
+		return false
+	}
+
+	return c.GetHeader("X-Session-Mode") == "cookie" || c.Query("session_mode") == "cookie"
+}
+
+// Response data of a new token pair. In cookie mode the tokens are set as HttpOnly
+// cookies and only the CSRF token the client has to send back is returned.
+func sessionTokens(c *gin.Context, cookieMode bool, userToken, refreshToken string) (gin.H, error) {
+	if !cookieMode {
+		return gin.H{"refresh_token": refreshToken, "user_token": userToken}, nil
+	}
+
+	csrfToken, err := utils.GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sameSite := utils.CookieSameSite()
+	userTokenTTL := utils.DurationFromEnv("JWT_EXP_DURATION", 2*time.Hour)
+	refreshTokenTTL := utils.DurationFromEnv("REF_EXP_DURATION", 4*time.Hour)
+
+	http.SetCookie(c.Writer, &http.Cookie{Name: utils.SessionCookie, Value: userToken, Path: "/", MaxAge: int(userTokenTTL.Seconds()), Secure: true, HttpOnly: true, SameSite: sameSite})
+	http.SetCookie(c.Writer, &http.Cookie{Name: utils.RefreshCookie, Value: refreshToken, Path: utils.RefreshCookiePath, MaxAge: int(refreshTokenTTL.Seconds()), Secure: true, HttpOnly: true, SameSite: sameSite})
+	//readable by scripts of the site so they can echo it in the CSRF header
+	http.SetCookie(c.Writer, &http.Cookie{Name: utils.CSRFCookie, Value: csrfToken, Path: "/", MaxAge: int(refreshTokenTTL.Seconds()), Secure: true, SameSite: sameSite})
+
+	return gin.H{"session_mode": "cookie", "csrf_token": csrfToken}, nil
+}
+
+// Expire the cookies of the cookie session mode
+func clearSessionCookies(c *gin.Context) {
+	if !utils.CookieSessionsEnabled() {
+		return
+	}
+
+	sameSite := utils.CookieSameSite()
+	http.SetCookie(c.Writer, &http.Cookie{Name: utils.SessionCookie, Path: "/", MaxAge: -1, Secure: true, HttpOnly: true, SameSite: sameSite})
+	http.SetCookie(c.Writer, &http.Cookie{Name: utils.RefreshCookie, Path: utils.RefreshCookiePath, MaxAge: -1, Secure: true, HttpOnly: true, SameSite: sameSite})
+	http.SetCookie(c.Writer, &http.Cookie{Name: utils.CSRFCookie, Path: "/", MaxAge: -1, Secure: true, SameSite: sameSite})
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// List the signed in sessions of user
+func GetSessions(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := dao.GetSessions(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch sessions", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch sessions", true, http.StatusBadRequest)
+		return
+	}
+
+	currentToken := middlewares.RequestToken(c)
+	sessions := make([]models.SessionResponse, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, models.SessionResponse{
+			ID:         t.ID,
+			DeviceName: t.DeviceName,
+			UserAgent:  t.UserAgent,
+			IP:         t.IP,
+			CreatedAt:  t.Timestamp,
+			LastSeenAt: t.LastSeenAt,
+			ClientID:   t.ClientID,
+			Current:    t.UserTokenHash == utils.HashToken(currentToken),
+		})
+	}
+
+	logger.Info(requestID, "Sessions fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, sessions, "sessions fetched successfully", false, http.StatusOK)
+}
+
+// Revoke a single session of user
+func DeleteSession(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	sessionId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(requestID, "failed to parse session id", c.Param("id"), err.Error())
+		utils.SetResponse(c, requestID, nil, "could not parse session id", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.DeleteSession(userId, sessionId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Warn(requestID, "session not found", err.Error(), "userID: "+strconv.Itoa(int(userId)), "sessionID: "+strconv.Itoa(int(sessionId)))
+		utils.SetResponse(c, requestID, nil, "session not found", true, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error(requestID, "failed to revoke session", err.Error(), "userID: "+strconv.Itoa(int(userId)), "sessionID: "+strconv.Itoa(int(sessionId)))
+		utils.SetResponse(c, requestID, nil, "failed to revoke session", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "Session revoked successfully", "userID: "+strconv.Itoa(int(userId)), "sessionID: "+strconv.Itoa(int(sessionId)))
+	utils.SetResponse(c, requestID, nil, "session revoked successfully", false, http.StatusOK)
+}
+
+// device metadata of the current request
+func sessionInfo(c *gin.Context) models.SessionInfo {
+	deviceName := strings.TrimSpace(c.GetHeader("X-Device-Name"))
+	if deviceName == "" {
+		deviceName = utils.DeviceNameFromUserAgent(c.Request.UserAgent())
+	}
+	if len(deviceName) > 100 {
+		deviceName = deviceName[:100]
+	}
+
+	return models.SessionInfo{
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		DeviceName: deviceName,
+	}
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// how long other services may cache the published keyset
+const jwksMaxAge = 5 * time.Minute
+
+// Serves the public keys of the access token keyset in JWKS format
+func GetJWKS(c *gin.Context) {
+	//other services cache the keyset, new keys are published before they sign
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(jwksMaxAge.Seconds())))
+	c.JSON(http.StatusOK, utils.JWKS())
+}
+
+// Loads the keyset and keeps it in sync with DB, rotating the signing key on schedule
+func ScheduleSigningKeyRotation() {
+	if err := utils.ValidateSigningAlgorithm(utils.SigningAlgorithm()); err != nil {
+		logger.Error("", "invalid JWT_ALG", err.Error())
+		return
+	}
+
+	if err := syncSigningKeys(); err != nil {
+		logger.Error("", "could not load signing keys", err.Error())
+	}
+
+	//picks up keys rotated by other instances as well
+	interval := keySyncInterval()
+	go func() {
+		for range time.Tick(interval) {
+			if err := syncSigningKeys(); err != nil {
+				logger.Error("", "could not sync signing keys", err.Error())
+			}
+		}
+	}()
+}
+
+// Rotates the signing key when due and publishes the stored keys to the keyset
+func syncSigningKeys() error {
+	alg := utils.SigningAlgorithm()
+
+	keys, err := dao.GetSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	rotation := utils.DurationFromEnv("JWT_KEY_ROTATION", 30*24*time.Hour)
+	due := len(keys) == 0 || keys[0].Algorithm != alg || time.Since(keys[0].CreatedAt) >= rotation
+	if alg != utils.AlgorithmHS256 && due {
+		kid, encoded, err := utils.GenerateSigningKey(alg)
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := utils.EncryptSigningKey(encoded)
+		if err != nil {
+			return err
+		}
+
+		key := dao.SigningKey{Kid: kid, Algorithm: alg, PrivateKeyEnc: encrypted}
+
+		//a replacement signs only once every instance has synced it and cached keysets have expired,
+		//a first key or a key for a new algorithm has nothing to wait for
+		if len(keys) > 0 && keys[0].Algorithm == alg {
+			activatesAt := time.Now().Add(keySyncInterval() + jwksMaxAge)
+			key.ActivatesAt = &activatesAt
+		}
+
+		//retired keys verify tokens until the longest lived user token has expired
+		retention := utils.DurationFromEnv("JWT_KEY_RETENTION", utils.DurationFromEnv("JWT_EXP_DURATION", 2*time.Hour))
+		if err := dao.RotateSigningKey(&key, retention); err != nil {
+			return err
+		}
+		logger.Info("", "signing key rotated", "kid: "+kid)
+
+		keys, err = dao.GetSigningKeys()
+		if err != nil {
+			return err
+		}
+	}
+
+	keySet := make([]utils.SigningKey, 0, len(keys))
+	for _, k := range keys {
+		decrypted, err := utils.DecryptSigningKey(k.PrivateKeyEnc)
+		if err != nil {
+			logger.Error("", "could not decrypt signing key", err.Error(), "kid: "+k.Kid)
+			continue
+		}
+
+		parsed, err := utils.ParseSigningKey(k.Kid, k.Algorithm, decrypted)
+		if err != nil {
+			logger.Error("", "could not parse signing key", err.Error(), "kid: "+k.Kid)
+			continue
+		}
+		if k.ActivatesAt != nil {
+			parsed.ActivatesAt = *k.ActivatesAt
+		}
+
+		keySet = append(keySet, *parsed)
+	}
+
+	utils.SetSigningKeys(keySet)
+	return nil
+}
+
+// interval in which instances reload the keyset from DB
+func keySyncInterval() time.Duration {
+	return utils.DurationFromEnv("JWT_KEY_SYNC_INTERVAL", 5*time.Minute)
+}
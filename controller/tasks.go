@@ -2,14 +2,17 @@ package controller
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"task_manager/dao"
 	"task_manager/logger"
 	"task_manager/middlewares"
 	"task_manager/models"
 	"task_manager/utils"
+	"time"
 
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
@@ -43,10 +46,44 @@ func CreateTask(c *gin.Context) {
 
 	task.UserID = userId
 
+	if task.EstimateUnit == "" {
+		task.EstimateUnit = "points"
+	}
+
+	//remaining effort starts at the full estimate unless provided
+	var requestData map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &requestData); err == nil {
+		if _, ok := requestData["remaining_effort"]; !ok {
+			task.RemainingEffort = task.Estimate
+		}
+	}
+
+	//validate estimate and remaining effort
+	err = utils.ValidateTaskEffort(task.Estimate, task.EstimateUnit, task.RemainingEffort)
+	if err != nil {
+		logger.Error(requestID, "invalid task effort", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	if task.Priority != "" && !utils.ValidPriority(task.Priority) {
+		logger.Error(requestID, "invalid task priority", task.Priority, "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "priority must be low, medium, high or urgent", true, http.StatusBadRequest)
+		return
+	}
+
+	//validate custom field values against user definitions
+	fieldValues, err := resolveCustomFieldValues(userId, task.CustomFields)
+	if err != nil {
+		logger.Error(requestID, "invalid custom field values", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
 	logger.Info(requestID, "Recieved task creation request", "userID: "+strconv.Itoa(int(userId)), requestBody)
 
 	//save task in db
-	err = dao.SaveTask(&task)
+	err = dao.SaveTask(&task, fieldValues)
 	if err != nil {
 		logger.Error(requestID, "failed to save task", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
 		utils.SetResponse(c, requestID, nil, "failed to create the task", true, http.StatusBadRequest)
@@ -57,6 +94,81 @@ func CreateTask(c *gin.Context) {
 	utils.SetResponse(c, requestID, gin.H{"taskId": task.ID}, "task created successfully", false, http.StatusCreated)
 }
 
+// create task from free text like "Pay rent every 1st of month !high #finance due tomorrow 9am"
+func QuickAddTask(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	bodyBytes, _ := io.ReadAll(c.Request.Body)
+	requestBody := string(bodyBytes)
+
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var req models.QuickAddRequest
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		logger.Error(requestID, "failed to parse request", err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, "text is required", true, http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := dao.GetPreferences(userId)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch preferences", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to create the task", true, http.StatusBadRequest)
+		return
+	}
+
+	//relative dates are resolved in the preferred timezone unless one is supplied
+	if req.Timezone == "" {
+		req.Timezone = prefs.Timezone
+	}
+
+	location, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		logger.Error(requestID, "invalid timezone", err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, "timezone must be a valid IANA timezone", true, http.StatusBadRequest)
+		return
+	}
+
+	//parse title and metadata from the text
+	parsed, err := utils.ParseQuickAdd(req.Text, time.Now().In(location), *prefs)
+	if err != nil {
+		logger.Error(requestID, "failed to parse task text", err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	task := models.Task{
+		Title:        parsed.Title,
+		Completed:    "false",
+		EstimateUnit: "points",
+		DueDate:      parsed.DueDate,
+		Priority:     parsed.Priority,
+		Tags:         strings.Join(parsed.Tags, ","),
+		Recurrence:   parsed.Recurrence,
+		UserID:       userId,
+	}
+
+	err = dao.SaveTask(&task, nil)
+	if err != nil {
+		logger.Error(requestID, "failed to save task", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "failed to create the task", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "task created successfully", "taskID: "+strconv.Itoa(int(task.ID)), "userID: "+strconv.Itoa(int(userId)), requestBody)
+	utils.SetResponse(c, requestID, gin.H{"task": task, "parsed": parsed}, "task created successfully", false, http.StatusCreated)
+}
+
 // fetch task by id
 func GetTask(c *gin.Context) {
 	requestID := requestid.Get(c)
@@ -113,9 +225,78 @@ func GetTasksByQuery(c *gin.Context) {
 		return
 	}
 
-	// Retrieve query parameters
-	sortOrder := c.DefaultQuery("sort", "asc")
+	prefs, err := dao.GetPreferences(userId.(int64))
+	if err != nil {
+		logger.Error(requestID, "failed to fetch preferences", "userID: "+strconv.Itoa(int(userId.(int64))), err.Error())
+		utils.SetResponse(c, requestID, nil, "could not fetch tasks", true, http.StatusBadRequest)
+		return
+	}
+
+	// Retrieve query parameters, sort and limit default to user preferences
+	sortOrder := c.DefaultQuery("sort", prefs.DefaultSort)
 	completed := c.Query("completed")
+	defaultLimit := strconv.Itoa(prefs.DefaultPageSize)
+
+	// Due filters are evaluated in the preferred timezone
+	var due models.DueRange
+	now := time.Now().In(utils.PreferredLocation(*prefs))
+	switch c.Query("due") {
+	case "":
+	case "today":
+		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		endOfDay := startOfDay.AddDate(0, 0, 1)
+		due = models.DueRange{From: &startOfDay, Before: &endOfDay}
+	case "overdue":
+		due = models.DueRange{Before: &now}
+		if completed == "" {
+			completed = "false"
+		}
+	default:
+		logger.Warn(requestID, "Invalid due parameter", c.Query("due"))
+		utils.SetResponse(c, requestID, nil, "due must be today or overdue", true, http.StatusBadRequest)
+		return
+	}
+
+	if sortOrder != "asc" && sortOrder != "desc" {
+		logger.Warn(requestID, "Invalid sort parameter", sortOrder)
+		utils.SetResponse(c, requestID, nil, "sort must be asc or desc", true, http.StatusBadRequest)
+		return
+	}
+
+	// Custom field filters are passed as cf.<field name>=<value>
+	var fieldFilters []models.CustomFieldFilter
+	for key, values := range c.Request.URL.Query() {
+		name, ok := strings.CutPrefix(key, "cf.")
+		if !ok {
+			continue
+		}
+
+		field, err := dao.GetCustomFieldByName(userId.(int64), name)
+		if err != nil {
+			logger.Warn(requestID, "Unknown custom field filter", name, err.Error())
+			utils.SetResponse(c, requestID, nil, "unknown custom field: "+name, true, http.StatusBadRequest)
+			return
+		}
+
+		value, err := utils.ParseCustomFieldFilter(*field, values[0])
+		if err != nil {
+			logger.Warn(requestID, "Invalid custom field filter", name, err.Error())
+			utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+		fieldFilters = append(fieldFilters, models.CustomFieldFilter{Field: *field, Value: value})
+	}
+
+	// Sort by custom field if sort_by is provided, otherwise by creation time
+	var sortField *models.CustomField
+	if sortBy := c.Query("sort_by"); sortBy != "" && sortBy != "created_at" {
+		sortField, err = dao.GetCustomFieldByName(userId.(int64), sortBy)
+		if err != nil {
+			logger.Warn(requestID, "Unknown sort field", sortBy, err.Error())
+			utils.SetResponse(c, requestID, nil, "unknown custom field: "+sortBy, true, http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Pagination parameters
 	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -124,16 +305,16 @@ func GetTasksByQuery(c *gin.Context) {
 		page = 1
 	}
 
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", defaultLimit))
 	if err != nil || limit < 1 {
-		logger.Warn(requestID, "Invalid limit parameter", c.DefaultQuery("limit", "5"), err.Error())
-		limit = 5
+		logger.Warn(requestID, "Invalid limit parameter", c.DefaultQuery("limit", defaultLimit))
+		limit = prefs.DefaultPageSize
 	}
 
 	offset := (page - 1) * limit
 
 	// Fetch tasks with filters, sorting, and pagination
-	tasks, totalTasks, err := dao.GetTasksWithFilters(userId.(int64), sortOrder, completed, limit, offset)
+	tasks, totalTasks, err := dao.GetTasksWithFilters(userId.(int64), sortOrder, completed, due, fieldFilters, sortField, limit, offset)
 	if err != nil {
 		logger.Error(requestID, "failed to fetch tasks", "userID: "+strconv.Itoa(int(userId.(int64))), err.Error())
 		utils.SetResponse(c, requestID, nil, "could not fetch tasks", true, http.StatusBadRequest)
@@ -185,11 +366,54 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
-	if task.Completed == "false" {
-		task.Completed = "true"
+	var req models.UpdateTaskRequest
+	if len(bytes.TrimSpace(bodyBytes)) > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userID)), requestBody)
+			utils.SetResponse(c, requestID, nil, "invalid request body", true, http.StatusBadRequest)
+			return
+		}
+	} else {
+		//a request without body completes the task as before
+		completed := true
+		req.Completed = &completed
+	}
+
+	if req.Estimate != nil {
+		task.Estimate = *req.Estimate
+	}
+	if req.EstimateUnit != nil {
+		task.EstimateUnit = *req.EstimateUnit
+	}
+	if req.RemainingEffort != nil {
+		task.RemainingEffort = *req.RemainingEffort
 	}
 
-	err = dao.Update(task)
+	if req.Completed != nil {
+		//no work remains on a completed task unless told otherwise
+		if *req.Completed && task.Completed == "false" && req.RemainingEffort == nil {
+			task.RemainingEffort = 0
+		}
+		task.Completed = strconv.FormatBool(*req.Completed)
+	}
+
+	//validate estimate and remaining effort
+	err = utils.ValidateTaskEffort(task.Estimate, task.EstimateUnit, task.RemainingEffort)
+	if err != nil {
+		logger.Error(requestID, "invalid task effort", err.Error(), "taskID: "+strconv.Itoa(int(taskId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	//validate custom field values against user definitions
+	fieldValues, err := resolveCustomFieldValues(userID, req.CustomFields)
+	if err != nil {
+		logger.Error(requestID, "invalid custom field values", err.Error(), "taskID: "+strconv.Itoa(int(taskId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.Update(task, fieldValues)
 	if err != nil {
 		logger.Error(requestID, "failed to update task", "taskID: "+strconv.Itoa(int(taskId)), err.Error(), requestBody)
 		utils.SetResponse(c, requestID, nil, "could not update task", true, http.StatusBadRequest)
@@ -242,3 +466,35 @@ func DeleteTask(c *gin.Context) {
 	logger.Info(requestID, "Task deleted successfully", "userID: "+strconv.Itoa(int(userID)), "taskID: "+strconv.Itoa(int(taskId)))
 	utils.SetResponse(c, requestID, nil, "task deleted successfully", false, http.StatusOK)
 }
+
+// summarize estimated and remaining effort across user tasks
+func GetTaskSummary(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := dao.GetPreferences(userId)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch preferences", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not summarize tasks", true, http.StatusBadRequest)
+		return
+	}
+
+	//weeks follow the preferred first day and timezone
+	summary, err := dao.GetTaskSummary(userId, utils.PreferredLocation(*prefs), utils.PreferredWeekStart(*prefs))
+	if err != nil {
+		logger.Error(requestID, "failed to summarize tasks", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not summarize tasks", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "task summary fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, summary, "task summary fetched successfully", false, http.StatusOK)
+}
@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// create task template for user
+func CreateTemplate(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	bodyBytes, _ := io.ReadAll(c.Request.Body)
+	requestBody := string(bodyBytes)
+
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var template models.TaskTemplate
+	err = c.ShouldBindJSON(&template)
+	if err != nil {
+		logger.Error(requestID, "failed to parse request", err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, "cannot parsed the requested data", true, http.StatusBadRequest)
+		return
+	}
+
+	//validate template name and items
+	err = utils.ValidateTemplate(&template)
+	if err != nil {
+		logger.Error(requestID, "invalid task template", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.SaveTemplate(userId, &template)
+	if err != nil {
+		logger.Error(requestID, "failed to save task template", err.Error(), "userID: "+strconv.Itoa(int(userId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "failed to create template, name may already exist", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "task template created successfully", "templateID: "+strconv.Itoa(int(template.ID)), "userID: "+strconv.Itoa(int(userId)), requestBody)
+	utils.SetResponse(c, requestID, gin.H{"templateId": template.ID}, "template created successfully", false, http.StatusCreated)
+}
+
+// fetch all task templates of user
+func GetTemplates(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	templates, err := dao.GetTemplates(userId)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch task templates", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch templates", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "task templates fetched successfully", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, templates, "templates fetched successfully", false, http.StatusOK)
+}
+
+// fetch task template by id
+func GetTemplate(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	templateId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(requestID, "failed to parse template id", c.Param("id"), err.Error())
+		utils.SetResponse(c, requestID, nil, "could not parse template id", true, http.StatusBadRequest)
+		return
+	}
+
+	template, err := dao.GetTemplateByID(templateId, userId)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch task template", err.Error(), "userID: "+strconv.Itoa(int(userId)), "templateID: "+strconv.Itoa(int(templateId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch template or access denied", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "task template fetched successfully", "userID: "+strconv.Itoa(int(userId)), "templateID: "+strconv.Itoa(int(templateId)))
+	utils.SetResponse(c, requestID, template, "template fetched successfully", false, http.StatusOK)
+}
+
+// delete task template
+func DeleteTemplate(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	templateId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(requestID, "failed to parse template id", c.Param("id"), err.Error())
+		utils.SetResponse(c, requestID, nil, "could not parse template id", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.DeleteTemplate(templateId, userId)
+	if err != nil {
+		logger.Error(requestID, "failed to delete task template", err.Error(), "userID: "+strconv.Itoa(int(userId)), "templateID: "+strconv.Itoa(int(templateId)))
+		utils.SetResponse(c, requestID, nil, "could not delete template", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "task template deleted successfully", "userID: "+strconv.Itoa(int(userId)), "templateID: "+strconv.Itoa(int(templateId)))
+	utils.SetResponse(c, requestID, nil, "template deleted successfully", false, http.StatusOK)
+}
+
+// create all tasks of a template relative to the anchor date
+func InstantiateTemplate(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	bodyBytes, _ := io.ReadAll(c.Request.Body)
+	requestBody := string(bodyBytes)
+
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	templateId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(requestID, "failed to parse template id", c.Param("id"), err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, "could not parse template id", true, http.StatusBadRequest)
+		return
+	}
+
+	var req models.InstantiateTemplateRequest
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		logger.Error(requestID, "failed to parse request", err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, "anchor_date is required", true, http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := dao.GetPreferences(userId)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch preferences", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to create tasks from template", true, http.StatusBadRequest)
+		return
+	}
+
+	//anchor date is a calendar day in the preferred timezone
+	anchorDate, err := time.ParseInLocation(time.DateOnly, req.AnchorDate, utils.PreferredLocation(*prefs))
+	if err != nil {
+		logger.Error(requestID, "invalid anchor date", err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, "anchor_date must be in YYYY-MM-DD format", true, http.StatusBadRequest)
+		return
+	}
+
+	template, err := dao.GetTemplateByID(templateId, userId)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch task template", err.Error(), "userID: "+strconv.Itoa(int(userId)), "templateID: "+strconv.Itoa(int(templateId)))
+		utils.SetResponse(c, requestID, nil, "could not fetch template or access denied", true, http.StatusBadRequest)
+		return
+	}
+
+	tasks := make([]models.Task, 0, len(template.Items))
+	for _, item := range template.Items {
+		dueDate := anchorDate.AddDate(0, 0, item.DayOffset)
+
+		//anchor_date and due_date are always available as placeholders
+		values := map[string]string{}
+		for k, v := range req.Values {
+			values[k] = v
+		}
+		values["anchor_date"] = anchorDate.Format(time.DateOnly)
+		values["due_date"] = dueDate.Format(time.DateOnly)
+
+		title, err := utils.RenderPlaceholders(item.Title, values)
+		if err != nil {
+			logger.Error(requestID, "failed to render template title", err.Error(), "templateID: "+strconv.Itoa(int(templateId)), requestBody)
+			utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+
+		description, err := utils.RenderPlaceholders(item.Description, values)
+		if err != nil {
+			logger.Error(requestID, "failed to render template description", err.Error(), "templateID: "+strconv.Itoa(int(templateId)), requestBody)
+			utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+
+		//template tasks are due at the end of their day
+		dueAt := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 23, 59, 59, 0, dueDate.Location())
+
+		tasks = append(tasks, models.Task{
+			Title:        title,
+			Description:  description,
+			Completed:    item.Completed,
+			EstimateUnit: "points",
+			DueDate:      &dueAt,
+			UserID:       userId,
+		})
+	}
+
+	//save all tasks in one transaction
+	err = dao.SaveTasks(tasks)
+	if err != nil {
+		logger.Error(requestID, "failed to save template tasks", err.Error(), "userID: "+strconv.Itoa(int(userId)), "templateID: "+strconv.Itoa(int(templateId)), requestBody)
+		utils.SetResponse(c, requestID, nil, "failed to create tasks from template", true, http.StatusBadRequest)
+		return
+	}
+
+	taskIds := make([]int64, 0, len(tasks))
+	for _, t := range tasks {
+		taskIds = append(taskIds, t.ID)
+	}
+
+	logger.Info(requestID, "template instantiated successfully", "userID: "+strconv.Itoa(int(userId)), "templateID: "+strconv.Itoa(int(templateId)), requestBody)
+	utils.SetResponse(c, requestID, gin.H{"taskIds": taskIds}, "tasks created from template successfully", false, http.StatusCreated)
+}
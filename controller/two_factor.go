@@ -0,0 +1,332 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/middlewares"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// number of recovery codes issued when two factor is confirmed
+const recoveryCodeCount = 10
+
+// Start two factor enrollment by issuing a new TOTP secret
+func EnrollTwoFactor(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	enabled, err := dao.IsTwoFactorEnabled(userId)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch two factor settings", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to enroll two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	if enabled {
+		logger.Warn(requestID, "two factor already enabled", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "two factor authentication already enabled", true, http.StatusBadRequest)
+		return
+	}
+
+	user, err := dao.GetUserById(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch user", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to enroll two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		logger.Error(requestID, "failed to generate two factor secret", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to enroll two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	//secret is stored encrypted
+	secretEnc, err := utils.EncryptSecret(secret)
+	if err != nil {
+		logger.Error(requestID, "failed to encrypt two factor secret", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to enroll two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	err = dao.SavePendingTwoFactor(userId, secretEnc)
+	if err != nil {
+		logger.Error(requestID, "failed to save two factor secret", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to enroll two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Task Manager"
+	}
+
+	logger.Info(requestID, "two factor enrollment started", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, gin.H{"secret": secret, "otpauth_uri": utils.TOTPURI(issuer, user.Email, secret)}, "scan the code and confirm with a generated code", false, http.StatusOK)
+}
+
+// Confirm two factor enrollment with a code and issue recovery codes
+func ConfirmTwoFactor(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "code required", true, http.StatusBadRequest)
+		return
+	}
+
+	twoFactor, err := dao.GetTwoFactor(userId)
+	if err != nil || twoFactor.Enabled {
+		logger.Warn(requestID, "no pending two factor enrollment", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "no pending two factor enrollment", true, http.StatusBadRequest)
+		return
+	}
+
+	secret, err := utils.DecryptSecret(twoFactor.SecretEnc)
+	if err != nil {
+		logger.Error(requestID, "failed to decrypt two factor secret", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to confirm two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	user, err := dao.GetUserById(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch user", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to confirm two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	//codes are guessed like passwords, failures count towards the account lockout
+	if reserveLoginAttempt(c, requestID, user.Email) {
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(secret, req.Code, time.Now())
+	if !ok {
+		recordLoginFailure(c, requestID, user.Email)
+		logger.Warn(requestID, "invalid two factor code", "", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "invalid two factor code", true, http.StatusBadRequest)
+		return
+	}
+
+	releaseLoginAttempt(c, requestID, user.Email)
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		logger.Error(requestID, "failed to generate recovery codes", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to confirm two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, utils.HashToken(code))
+	}
+
+	err = dao.EnableTwoFactor(userId, step, hashes)
+	if err != nil {
+		logger.Error(requestID, "failed to enable two factor", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to confirm two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "two factor enabled", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, gin.H{"recovery_codes": codes}, "two factor authentication enabled, store the recovery codes safely", false, http.StatusOK)
+}
+
+// Disable two factor after verifying a code
+func DisableTwoFactor(c *gin.Context) {
+	requestID := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	//checks whether user is signin or not
+	err := middlewares.CheckTokenPresent(c)
+	if err != nil {
+		logger.Warn(requestID, "session expired or token not found", "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "session expired or token not found", true, http.StatusBadRequest)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(requestID, "Invalid request body", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "code required", true, http.StatusBadRequest)
+		return
+	}
+
+	user, err := dao.GetUserById(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch user", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to disable two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	//a stolen access token must not be enough to guess the code
+	if reserveLoginAttempt(c, requestID, user.Email) {
+		return
+	}
+
+	err = verifySecondFactor(userId, req.Code, "")
+	if err != nil {
+		recordLoginFailure(c, requestID, user.Email)
+		logger.Warn(requestID, "two factor verification failed", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	releaseLoginAttempt(c, requestID, user.Email)
+
+	err = dao.DisableTwoFactor(userId)
+	if err != nil {
+		logger.Error(requestID, "failed to disable two factor", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "failed to disable two factor", true, http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.SaveAuditLog("two_factor_disabled", userId, c.ClientIP(), ""); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+	}
+
+	utils.SendMailAsync(requestID, user.Email, "Two factor authentication was disabled",
+		"Two factor authentication was turned off for your account. Signing in now only requires your password.\n\n"+
+			"If you did not make this change, reset your password and contact support right away.")
+
+	logger.Info(requestID, "two factor disabled", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, nil, "two factor authentication disabled", false, http.StatusOK)
+}
+
+// Complete sign in by exchanging the challenge token and a code for the token pair
+func SignInTwoFactor(c *gin.Context) {
+	requestID := requestid.Get(c)
+
+	var req models.TwoFactorSignInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Warn(requestID, "failed to parse two factor sign in request", err.Error())
+		utils.SetResponse(c, requestID, nil, "challenge token required", true, http.StatusBadRequest)
+		return
+	}
+
+	userId, scopes, err := utils.VerifyChallengeToken(req.ChallengeToken)
+	if err != nil {
+		logger.Warn(requestID, "invalid challenge token", err.Error())
+		utils.SetResponse(c, requestID, nil, "invalid or expired challenge token", true, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := dao.GetUserById(userId)
+	if err != nil {
+		logger.Error(requestID, "could not fetch user", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	//second factor failures count towards the account lockout
+	if reserveLoginAttempt(c, requestID, user.Email) {
+		return
+	}
+
+	err = verifySecondFactor(userId, req.Code, req.RecoveryCode)
+	if err != nil {
+		recordLoginFailure(c, requestID, user.Email)
+		logger.Warn(requestID, "two factor verification failed", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusUnauthorized)
+		return
+	}
+
+	releaseLoginAttempt(c, requestID, user.Email)
+	recordLoginSuccess(requestID, user.Email)
+
+	//generating token
+	userToken, refreshToken, err := utils.GenerateTokens(userId, scopes)
+	if err != nil {
+		logger.Error(requestID, "failed to generate tokens", "userID: "+strconv.Itoa(int(userId)), err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	//save pair of token
+	err = dao.SaveToken(userId, userToken, refreshToken, sessionInfo(c))
+	if err != nil {
+		logger.Error(requestID, "failed to save tokens", "userID: "+strconv.Itoa(int(userId)), err.Error())
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	data, err := sessionTokens(c, wantsCookieSession(c), userToken, refreshToken)
+	if err != nil {
+		logger.Error(requestID, "failed to set session cookies", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info(requestID, "user signed in successfully with two factor", "userID: "+strconv.Itoa(int(userId)))
+	utils.SetResponse(c, requestID, data, "user sign in successfully", false, http.StatusCreated)
+}
+
+// check a TOTP code or, when given, a one time recovery code
+func verifySecondFactor(userId int64, code, recoveryCode string) error {
+	twoFactor, err := dao.GetTwoFactor(userId)
+	if err != nil || !twoFactor.Enabled {
+		return errors.New("two factor authentication not enabled")
+	}
+
+	if recoveryCode != "" {
+		used, err := dao.UseRecoveryCode(userId, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errors.New("invalid recovery code")
+		}
+		return nil
+	}
+
+	secret, err := utils.DecryptSecret(twoFactor.SecretEnc)
+	if err != nil {
+		return err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return errors.New("invalid two factor code")
+	}
+
+	//a code can only be used once
+	fresh, err := dao.UseTwoFactorStep(userId, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errors.New("two factor code already used")
+	}
+
+	return nil
+}
@@ -3,10 +3,12 @@ package controller
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"task_manager/dao"
 	"task_manager/logger"
@@ -55,15 +57,28 @@ func SignUp(c *gin.Context) {
 	user.Gender = strings.ToLower(user.Gender)
 
 	//Save tokens
-	uid, userToken, refreshToken, err := dao.SaveUser(dao.DB, &user)
+	uid, userToken, refreshToken, err := dao.SaveUser(dao.DB, &user, sessionInfo(c))
 	if err != nil {
 		logger.Error(requestID, "Unable to save user.User already exists.", err.Error(), "userID: "+strconv.Itoa(int(user.ID)), requestBody)
 		utils.SetResponse(c, requestID, nil, "Unable to save user.User already exists.", true, http.StatusBadRequest)
 		return
 	}
 
+	//send verification email, the account stays usable if this fails
+	err = sendVerificationEmail(requestID, uid, user.Email)
+	if err != nil {
+		logger.Error(requestID, "failed to send verification email", err.Error(), "userID: "+strconv.Itoa(int(uid)))
+	}
+
+	data, err := sessionTokens(c, wantsCookieSession(c), userToken, refreshToken)
+	if err != nil {
+		logger.Error(requestID, "failed to set session cookies", err.Error(), "userID: "+strconv.Itoa(int(uid)), requestBody)
+		utils.SetResponse(c, requestID, nil, "failed to start session", true, http.StatusBadRequest)
+		return
+	}
+
 	logger.Info(requestID, "User registered successfully", "userID: "+strconv.Itoa(int(uid)), requestBody)
-	utils.SetResponse(c, requestID, gin.H{"refresh_token": refreshToken, "user_token": userToken}, "User registered successfully", false, http.StatusCreated)
+	utils.SetResponse(c, requestID, data, "User registered successfully", false, http.StatusCreated)
 }
 
 // user sign in
@@ -101,16 +116,57 @@ func SignIn(c *gin.Context) {
 		return
 	}
 
+	//reject attempts while the account or ip is backing off or locked
+	if reserveLoginAttempt(c, requestID, login.Email) {
+		return
+	}
+
+	scopes := utils.SessionScopes(login.ReadOnly)
+
 	//validate credentials to check whether the user has aaccount or not
-	err = dao.ValidateCredentials(&login)
+	err = dao.ValidateCredentials(requestID, &login)
 	if err != nil {
+		recordLoginFailure(c, requestID, login.Email)
 		logger.Warn(requestID, "Authentication failed", err.Error(), requestBody)
 		utils.SetResponse(c, requestID, nil, "incorrect username or password", true, http.StatusBadRequest)
 		return
 	}
+	releaseLoginAttempt(c, requestID, login.Email)
+
+	//passwords set under an older policy still sign in but have to be changed
+	policyViolation := passwordPolicyViolation(requestID, login.ID, login.Email, login.Password)
+
+	//users with two factor get a challenge token instead of the token pair
+	twoFactorEnabled, err := dao.IsTwoFactorEnabled(login.ID)
+	if err != nil {
+		logger.Error(requestID, "failed to fetch two factor settings", "userID: "+strconv.Itoa(int(login.ID)), err.Error(), requestBody)
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+
+	//with two factor the attempts are cleared only after the second step
+	if twoFactorEnabled {
+		challengeToken, err := utils.GenerateChallengeToken(login.ID, scopes)
+		if err != nil {
+			logger.Error(requestID, "failed to generate challenge token", "userID: "+strconv.Itoa(int(login.ID)), err.Error(), requestBody)
+			utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+			return
+		}
+
+		logger.Info(requestID, "two factor challenge issued", "userID: "+strconv.Itoa(int(login.ID)), requestBody)
+		data := gin.H{"two_factor_required": true, "challenge_token": challengeToken}
+		if policyViolation != "" {
+			data["password_change_required"] = true
+			data["password_policy"] = policyViolation
+		}
+		utils.SetResponse(c, requestID, data, "two factor code required", false, http.StatusOK)
+		return
+	}
+
+	recordLoginSuccess(requestID, login.Email)
 
 	//generating token
-	userToken, refreshToken, err := utils.GenerateTokens(login.ID)
+	userToken, refreshToken, err := utils.GenerateTokens(login.ID, scopes)
 	if err != nil {
 		logger.Error(requestID, "failed to generate tokens", "userID: "+strconv.Itoa(int(login.ID)), err.Error(), requestBody)
 		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
@@ -118,15 +174,31 @@ func SignIn(c *gin.Context) {
 	}
 
 	//save pair of token
-	err = dao.SaveToken(login.ID, userToken, refreshToken)
+	err = dao.SaveToken(login.ID, userToken, refreshToken, sessionInfo(c))
+	if errors.Is(err, dao.ErrAccountDeletionScheduled) {
+		logger.Warn(requestID, "sign in to account scheduled for deletion", err.Error(), "userID: "+strconv.Itoa(int(login.ID)))
+		utils.SetResponse(c, requestID, gin.H{"deletion_scheduled": true}, "account is scheduled for deletion, restore it with /user/restore to sign in", true, http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		logger.Error(requestID, "failed to save tokens", "userID: "+strconv.Itoa(int(login.ID)), err.Error(), requestBody)
 		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
 		return
 	}
 
+	data, err := sessionTokens(c, wantsCookieSession(c), userToken, refreshToken)
+	if err != nil {
+		logger.Error(requestID, "failed to set session cookies", err.Error(), "userID: "+strconv.Itoa(int(login.ID)), requestBody)
+		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
+		return
+	}
+	if policyViolation != "" {
+		data["password_change_required"] = true
+		data["password_policy"] = policyViolation
+	}
+
 	logger.Info(requestID, "user signed in successfully", "userID: "+strconv.Itoa(int(login.ID)), requestBody)
-	utils.SetResponse(c, requestID, gin.H{"refresh_token": refreshToken, "user_token": userToken}, "user sign in successfully", false, http.StatusCreated)
+	utils.SetResponse(c, requestID, data, "user sign in successfully", false, http.StatusCreated)
 }
 
 // Fetch the user details
@@ -165,23 +237,28 @@ func GetUser(c *gin.Context) {
 
 func RefreshTokenHandler(c *gin.Context) {
 	requestID := requestid.Get(c)
-	// Get the refresh token from the request header
-	err := middlewares.CheckRefreshToken(c)
-	if err != nil {
+	refreshToken := middlewares.RequestRefreshToken(c)
+	if refreshToken == "" {
 		logger.Error(requestID, "refresh token required", "")
 		utils.SetResponse(c, requestID, nil, "refresh token required", true, http.StatusUnauthorized)
 		return
 	}
 
-	refreshToken := c.GetHeader("Refresh-Token")
-	if refreshToken == "" {
+	//a refresh token that was already rotated means the family is compromised
+	if rejectReusedRefreshToken(c, refreshToken) {
+		return
+	}
+
+	// Get the refresh token from the request header
+	err := middlewares.CheckRefreshToken(c)
+	if err != nil {
 		logger.Error(requestID, "refresh token required", "")
 		utils.SetResponse(c, requestID, nil, "refresh token required", true, http.StatusUnauthorized)
 		return
 	}
 
 	// Verify the refresh token
-	userId, err := utils.VerifyRefreshToken(refreshToken)
+	claims, err := utils.VerifyRefreshToken(refreshToken)
 	if err != nil {
 		err = dao.DeleteRefreshToken(refreshToken)
 		if err != nil {
@@ -190,36 +267,112 @@ func RefreshTokenHandler(c *gin.Context) {
 			return
 		}
 
-		logger.Error(requestID, "invalid refresh token", err.Error())
+		logger.Error(requestID, "invalid refresh token", "")
 		utils.SetResponse(c, requestID, nil, "invalid refresh token", true, http.StatusUnauthorized)
 		return
 	}
 
+	userId := claims.UserID
+
+	//clients have to authenticate to refresh, which only the token endpoint does (RFC 6749 section 6)
+	if claims.ClientID != "" {
+		logger.Warn(requestID, "client refresh token sent to user refresh", "client_id: "+claims.ClientID, "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "refresh tokens of an OAuth client must be refreshed at /oauth/token", true, http.StatusBadRequest)
+		return
+	}
+
+	//the new pair continues the session of the refresh token
+	session, err := dao.GetSessionByRefreshToken(refreshToken)
+	if err != nil {
+		logger.Error(requestID, "session of refresh token not found", "userID: "+strconv.Itoa(int(userId)), err.Error())
+		utils.SetResponse(c, requestID, nil, "invalid refresh token", true, http.StatusUnauthorized)
+		return
+	}
+
+	info := dao.SessionInfoOf(session)
+	info.IP = c.ClientIP()
+
 	// Generate a new access token
-	newUserToken, newRefreshToken, err := utils.GenerateTokens(userId)
+	newUserToken, newRefreshToken, err := utils.GenerateTokens(userId, claims.Scopes)
 	if err != nil {
 		logger.Error(requestID, "failed to generate tokens", "userID: "+strconv.Itoa(int(userId)), err.Error())
 		utils.SetResponse(c, requestID, nil, "user login failed", true, http.StatusBadRequest)
 		return
 	}
 
-	err = dao.SaveToken(userId, newUserToken, newRefreshToken)
+	//replaces the old pair and remembers the old refresh token as rotated
+	rotated, err := dao.RotateRefreshToken(session, newUserToken, newRefreshToken, info, utils.DurationFromEnv("REF_EXP_DURATION", 4*time.Hour))
 	if err != nil {
-		logger.Error(requestID, "could not save token", "userID: "+strconv.Itoa(int(userId)), err.Error())
+		logger.Error(requestID, "could not rotate refresh token", "userID: "+strconv.Itoa(int(userId)), err.Error())
 		utils.SetResponse(c, requestID, nil, "could not save tokens", true, http.StatusBadRequest)
 		return
 	}
 
-	err = dao.DeleteRefreshToken(refreshToken)
+	//another request rotated the same token first, or it was signed out meanwhile
+	if !rotated {
+		if !rejectReusedRefreshToken(c, refreshToken) {
+			logger.Warn(requestID, "refresh token no longer valid", "", "userID: "+strconv.Itoa(int(userId)))
+			utils.SetResponse(c, requestID, nil, "invalid refresh token", true, http.StatusUnauthorized)
+		}
+		return
+	}
+
+	//a refresh token sent as cookie gets the new pair as cookies
+	cookieMode := wantsCookieSession(c) || c.GetHeader("Refresh-Token") == ""
+	data, err := sessionTokens(c, cookieMode, newUserToken, newRefreshToken)
 	if err != nil {
-		logger.Error(requestID, "failed to delete refresh token", "userID: "+strconv.Itoa(int(userId)), err.Error())
-		utils.SetResponse(c, requestID, nil, "failed to delete refresh token", true, http.StatusBadRequest)
+		logger.Error(requestID, "failed to set session cookies", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		utils.SetResponse(c, requestID, nil, "could not save tokens", true, http.StatusBadRequest)
 		return
 	}
 
 	// Return the new access token to the client
 	logger.Info(requestID, "token refreshed successfully", "userID: "+strconv.Itoa(int(userId)))
-	utils.SetResponse(c, requestID, gin.H{"refresh_token": newRefreshToken, "user_token": newUserToken}, "token refreshed successfully", false, http.StatusOK)
+	utils.SetResponse(c, requestID, data, "token refreshed successfully", false, http.StatusOK)
+}
+
+// Revokes the whole token family when an already rotated refresh token is presented again
+func rejectReusedRefreshToken(c *gin.Context, refreshToken string) bool {
+	requestID := requestid.Get(c)
+
+	reused, err := revokeReusedRefreshToken(c, refreshToken)
+	if err != nil {
+		utils.SetResponse(c, requestID, nil, "could not refresh token", true, http.StatusInternalServerError)
+		return true
+	}
+	if !reused {
+		return false
+	}
+
+	utils.SetResponse(c, requestID, nil, "invalid refresh token", true, http.StatusUnauthorized)
+	return true
+}
+
+// Detects reuse of a rotated refresh token, revoking its family and recording a security event
+func revokeReusedRefreshToken(c *gin.Context, refreshToken string) (bool, error) {
+	requestID := requestid.Get(c)
+
+	rotated, err := dao.GetRotatedToken(refreshToken)
+	if err != nil {
+		logger.Error(requestID, "could not check refresh token reuse", err.Error())
+		return false, err
+	}
+	if rotated == nil {
+		return false, nil
+	}
+
+	revoked, err := dao.RevokeTokenFamily(rotated.FamilyID)
+	if err != nil {
+		logger.Error(requestID, "could not revoke token family", err.Error(), "userID: "+strconv.Itoa(int(rotated.UserID)))
+		return false, err
+	}
+
+	logger.Warn(requestID, "refresh token reuse detected, token family revoked", "userID: "+strconv.Itoa(int(rotated.UserID)), "sessions revoked: "+strconv.Itoa(int(revoked)))
+	if err := dao.SaveAuditLog("refresh_token_reuse", rotated.UserID, c.ClientIP(), "token family revoked, "+strconv.Itoa(int(revoked))+" sessions"); err != nil {
+		logger.Error(requestID, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(rotated.UserID)))
+	}
+
+	return true, nil
 }
 
 // Updates user details
@@ -291,9 +444,7 @@ func UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	token := c.Request.Header.Get("Authorization")
-
-	token = strings.TrimPrefix(token, "Bearer ")
+	token := middlewares.RequestToken(c)
 
 	// Bind JSON request to struct
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -302,8 +453,16 @@ func UpdatePassword(c *gin.Context) {
 		return
 	}
 
+	//name and email of the user must not be part of the password
+	profile, err := dao.GetUserById(userIdFromToken.(int64))
+	if err != nil {
+		logger.Error(requestID, "User not found", err.Error(), "userID: "+strconv.Itoa(int(userIdFromToken.(int64))))
+		utils.SetResponse(c, requestID, nil, "user not found", true, http.StatusBadRequest)
+		return
+	}
+
 	//Validate whether enter password is in correct format or not
-	err = utils.ValidatePassword(req.OldPassword,req.NewPassword)
+	err = utils.ValidatePassword(req.OldPassword, req.NewPassword, profile.Email, profile.Name)
 	if err != nil {
 		logger.Error(requestID, "unable to validate credentials", err.Error(), "userID: "+strconv.Itoa(int(userIdFromToken.(int64))))
 		utils.SetResponse(c, requestID, nil, err.Error(), true, http.StatusBadRequest)
@@ -326,6 +485,12 @@ func UpdatePassword(c *gin.Context) {
 		return
 	}
 
+	//the new password must differ from the current and recent ones
+	policy := utils.GetPasswordPolicy()
+	if rejectReusedPassword(c, requestID, userIdFromToken.(int64), req.NewPassword, policy.HistorySize) {
+		return
+	}
+
 	//Hash the new password
 	hashedPassword, err := utils.HashPassword(req.NewPassword)
 	if err != nil {
@@ -335,7 +500,7 @@ func UpdatePassword(c *gin.Context) {
 	}
 
 	//Update password by userId
-	err = dao.UpdatePassById(userIdFromToken.(int64), hashedPassword)
+	err = dao.UpdatePassById(userIdFromToken.(int64), hashedPassword, policy.HistorySize)
 	if err != nil {
 		logger.Error(requestID, "failed to update password", err.Error(), "userID: "+strconv.Itoa(int(userIdFromToken.(int64))))
 		utils.SetResponse(c, requestID, nil, "failed to update password", true, http.StatusBadRequest)
@@ -373,15 +538,13 @@ func SignOut(c *gin.Context) {
 		return
 	}
 
-	tokenString := strings.TrimSpace(c.GetHeader("Authorization"))
+	tokenString := strings.TrimSpace(middlewares.RequestToken(c))
 	if tokenString == "" {
 		logger.Error(requestID, "Unable to validate user details", "")
 		utils.SetResponse(c, requestID, nil, "token not provided", true, http.StatusUnauthorized)
 		return
 	}
 
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-
 	//takes query parameters
 	allParam := c.DefaultQuery("all", "false")
 
@@ -393,6 +556,9 @@ func SignOut(c *gin.Context) {
 
 	}
 
+	//the cookies of this browser are expired either way
+	clearSessionCookies(c)
+
 	//if all query param value is true
 
 	if all {
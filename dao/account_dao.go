@@ -0,0 +1,160 @@
+package dao
+
+import (
+	"errors"
+	"task_manager/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAccountDeletionScheduled = errors.New("account is scheduled for deletion")
+var ErrAccountDeletionNotScheduled = errors.New("account is not scheduled for deletion")
+
+// Fetch everything exported for user. Missing avatar is returned as nil.
+func GetAccountExport(uid int64) (*User, []Task, *Avatar, []Token, error) {
+	var user User
+	if err := DB.Where("id = ?", uid).First(&user).Error; err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var tasks []Task
+	if err := DB.Where("user_id = ?", uid).Order("id").Find(&tasks).Error; err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if err := attachCustomFieldsToList(tasks); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var avatar *Avatar
+	var found Avatar
+	err := DB.Where("user_id = ?", uid).First(&found).Error
+	if err == nil {
+		avatar = &found
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil, nil, err
+	}
+
+	sessions, err := GetSessions(uid)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return &user, tasks, avatar, sessions, nil
+}
+
+// Signs out every session and schedules the account for hard deletion
+func ScheduleAccountDeletion(uid int64, deleteAt time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? AND deletion_scheduled_at IS NULL", uid).Update("deletion_scheduled_at", deleteAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrAccountDeletionScheduled
+		}
+
+		if err := tx.Where("user_id = ?", uid).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", uid).Delete(&PersonalAccessToken{}).Error
+	})
+}
+
+// Cancel a scheduled deletion during the grace period
+func CancelAccountDeletion(uid int64) error {
+	result := DB.Model(&User{}).Where("id = ? AND deletion_scheduled_at IS NOT NULL", uid).Update("deletion_scheduled_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return ErrAccountDeletionNotScheduled
+	}
+
+	return nil
+}
+
+// Fetch the accounts whose grace period is over
+func GetAccountsDueForDeletion(now time.Time) ([]int64, error) {
+	var ids []int64
+	if err := DB.Model(&User{}).Where("deletion_scheduled_at <= ?", now).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Hard deletes a user with every row they own. Audit logs keep the user id but lose the ip.
+func DeleteAccount(uid int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("id = ?", uid).First(&user).Error; err != nil {
+			return err
+		}
+
+		//clients of the user go with every token and grant issued to them
+		clients := tx.Model(&Client{}).Select("client_id").Where("user_id = ?", uid)
+		tasks := tx.Model(&Task{}).Select("id").Where("user_id = ?", uid)
+		templates := tx.Model(&TaskTemplate{}).Select("id").Where("user_id = ?", uid)
+
+		steps := []struct {
+			model any
+			query string
+			args  []any
+		}{
+			{&TaskFieldValue{}, "task_id IN (?)", []any{tasks}},
+			{&Task{}, "user_id = ?", []any{uid}},
+			{&TaskTemplateItem{}, "template_id IN (?)", []any{templates}},
+			{&TaskTemplate{}, "user_id = ?", []any{uid}},
+			{&CustomField{}, "user_id = ?", []any{uid}},
+			{&UserPreference{}, "user_id = ?", []any{uid}},
+			{&Avatar{}, "user_id = ?", []any{uid}},
+			{&Token{}, "user_id = ? OR client_id IN (?)", []any{uid, clients}},
+			{&RotatedToken{}, "user_id = ?", []any{uid}},
+			{&AuthorizationCode{}, "user_id = ? OR client_id IN (?)", []any{uid, clients}},
+			{&Consent{}, "user_id = ? OR client_id IN (?)", []any{uid, clients}},
+			{&Client{}, "user_id = ?", []any{uid}},
+			{&PersonalAccessToken{}, "user_id = ?", []any{uid}},
+			{&ExternalIdentity{}, "user_id = ?", []any{uid}},
+			{&RecoveryCode{}, "user_id = ?", []any{uid}},
+			{&TwoFactor{}, "user_id = ?", []any{uid}},
+			{&PasswordReset{}, "user_id = ?", []any{uid}},
+			{&PasswordHistory{}, "user_id = ?", []any{uid}},
+			{&EmailVerification{}, "user_id = ?", []any{uid}},
+			{&EmailChange{}, "user_id = ?", []any{uid}},
+			{&MagicLink{}, "user_id = ?", []any{uid}},
+			{&AccountUnlock{}, "user_id = ?", []any{uid}},
+			{&LoginAttempt{}, "attempt_key = ?", []any{utils.AccountAttemptKey(user.Email)}},
+			{&Login{}, "user_id = ?", []any{uid}},
+		}
+		for _, step := range steps {
+			if err := tx.Where(step.query, step.args...).Delete(step.model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&AuditLog{}).Where("user_id = ?", uid).Update("ip", "").Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&user).Error
+	})
+}
+
+// Rejects accounts that are disabled or waiting for hard deletion
+func checkAccountActive(tx *gorm.DB, uid int64) error {
+	var user User
+	if err := tx.Select("disabled_at", "deletion_scheduled_at").Where("id = ?", uid).Limit(1).Find(&user).Error; err != nil {
+		return err
+	}
+
+	if user.DisabledAt != nil {
+		return ErrAccountDisabled
+	}
+	if user.DeletionScheduledAt != nil {
+		return ErrAccountDeletionScheduled
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package dao
+
+import (
+	"errors"
+	"task_manager/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const RoleUser = "user"
+const RoleAdmin = "admin"
+
+var ErrAccountDisabled = errors.New("account is disabled")
+var ErrAccountNotDisabled = errors.New("account is not disabled")
+
+// Fetch the role of user
+func GetUserRole(uid int64) (string, error) {
+	var user User
+	if err := DB.Select("role").Where("id = ?", uid).First(&user).Error; err != nil {
+		return "", err
+	}
+
+	return user.Role, nil
+}
+
+// Promote the verified user with the email to admin while no admin exists.
+// Returns the id of the promoted user, or 0 when there already is an admin.
+func BootstrapAdmin(email string) (int64, error) {
+	var uid int64
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var admins int64
+		if err := tx.Model(&User{}).Where("role = ?", RoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins > 0 {
+			return nil
+		}
+
+		//an unverified address could have been registered by anyone
+		var user User
+		if err := tx.Where("email = ? AND email_verified = ?", email, true).First(&user).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&user).Update("role", RoleAdmin).Error; err != nil {
+			return err
+		}
+		uid = user.ID
+		return nil
+	})
+
+	return uid, err
+}
+
+// Fetch a page of users whose name or email contains search, with the total count
+func ListUsers(search string, limit, offset int) ([]User, int64, error) {
+	query := DB.Model(&User{})
+	if search != "" {
+		pattern := "%" + search + "%"
+		query = query.Where("name LIKE ? OR email LIKE ?", pattern, pattern)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []User
+	if err := query.Order("id").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// Fetch a user with every column
+func GetUser(uid int64) (*User, error) {
+	var user User
+	if err := DB.Where("id = ?", uid).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Disable an account and sign out every session. Personal access tokens are kept
+// but rejected while the account is disabled.
+func DisableUser(uid int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("id = ?", uid).First(&user).Error; err != nil {
+			return err
+		}
+		if user.DisabledAt != nil {
+			return ErrAccountDisabled
+		}
+
+		if err := tx.Model(&user).Update("disabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", uid).Delete(&Token{}).Error
+	})
+}
+
+// Enable a disabled account
+func EnableUser(uid int64) error {
+	var user User
+	if err := DB.Where("id = ?", uid).First(&user).Error; err != nil {
+		return err
+	}
+	if user.DisabledAt == nil {
+		return ErrAccountNotDisabled
+	}
+
+	return DB.Model(&user).Update("disabled_at", nil).Error
+}
+
+// Replace the password of user with an unusable hash, sign out every session, revoke
+// personal access tokens and save a reset token so that the user has to choose a new password
+func ForcePasswordReset(uid int64, unusableHash, tokenHash string, expiresAt time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Login{}).Where("user_id = ?", uid).Update("password", unusableHash)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := revokeAllAccess(tx, uid); err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ? AND used_at IS NULL", uid).Delete(&PasswordReset{}).Error; err != nil {
+			return err
+		}
+
+		reset := PasswordReset{
+			TokenHash: tokenHash,
+			ExpiresAt: expiresAt,
+			UserID:    uid,
+		}
+		return tx.Create(&reset).Error
+	})
+}
+
+// Count users, sessions and tasks for the admin overview
+func GetSystemStats() (*models.SystemStats, error) {
+	var stats models.SystemStats
+	now := time.Now()
+
+	counts := []struct {
+		target *int64
+		model  any
+		query  string
+		args   []any
+	}{
+		{&stats.Users, &User{}, "", nil},
+		{&stats.VerifiedUsers, &User{}, "email_verified = ?", []any{true}},
+		{&stats.DisabledUsers, &User{}, "disabled_at IS NOT NULL", nil},
+		{&stats.PendingDeletion, &User{}, "deletion_scheduled_at IS NOT NULL", nil},
+		{&stats.Admins, &User{}, "role = ?", []any{RoleAdmin}},
+		{&stats.SignUpsLastWeek, &User{}, "created_at > ?", []any{now.AddDate(0, 0, -7)}},
+		{&stats.Sessions, &Token{}, "", nil},
+		{&stats.PersonalAccessTokens, &PersonalAccessToken{}, "expires_at IS NULL OR expires_at > ?", []any{now}},
+		{&stats.OAuthClients, &Client{}, "", nil},
+		{&stats.Tasks, &Task{}, "", nil},
+		{&stats.CompletedTasks, &Task{}, "completed = ?", []any{"true"}},
+	}
+	for _, count := range counts {
+		query := DB.Model(count.model)
+		if count.query != "" {
+			query = query.Where(count.query, count.args...)
+		}
+		if err := query.Count(count.target).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &stats, nil
+}
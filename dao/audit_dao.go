@@ -0,0 +1,15 @@
+package dao
+
+// Save an audit log entry, uid is 0 when no user is involved
+func SaveAuditLog(event string, uid int64, ip, detail string) error {
+	entry := AuditLog{
+		Event:  event,
+		IP:     ip,
+		Detail: detail,
+	}
+	if uid != 0 {
+		entry.UserID = &uid
+	}
+
+	return DB.Create(&entry).Error
+}
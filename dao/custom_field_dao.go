@@ -0,0 +1,201 @@
+package dao
+
+import (
+	"encoding/json"
+	"strconv"
+	"task_manager/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// save custom field definition in db
+func SaveCustomField(uid int64, f *models.CustomField) error {
+	options, _ := json.Marshal(f.Options)
+	field := CustomField{
+		Name:    f.Name,
+		Type:    f.Type,
+		Options: string(options),
+		UserID:  uid,
+	}
+
+	if err := DB.Create(&field).Error; err != nil {
+		return err
+	}
+
+	f.ID = field.ID
+	return nil
+}
+
+// fetch all custom field definitions of user
+func GetCustomFields(uid int64) ([]models.CustomField, error) {
+	var fields []CustomField
+	if err := DB.Where("user_id = ?", uid).Order("id").Find(&fields).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]models.CustomField, 0, len(fields))
+	for _, f := range fields {
+		result = append(result, toCustomFieldModel(f))
+	}
+
+	return result, nil
+}
+
+// fetch custom field definition of user by name
+func GetCustomFieldByName(uid int64, name string) (*models.CustomField, error) {
+	var field CustomField
+	if err := DB.Where("user_id = ? AND name = ?", uid, name).First(&field).Error; err != nil {
+		return nil, err
+	}
+
+	result := toCustomFieldModel(field)
+	return &result, nil
+}
+
+// delete custom field definition along with its values
+func DeleteCustomField(uid, id int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var field CustomField
+		if err := tx.Where("id = ? AND user_id = ?", id, uid).First(&field).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("field_id = ?", field.ID).Delete(&TaskFieldValue{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&field).Error
+	})
+}
+
+// upsert custom field values of task, nil values are removed
+func saveTaskFieldValues(tx *gorm.DB, taskID int64, values map[int64]*models.CustomFieldValue) error {
+	for fieldID, v := range values {
+		if v == nil {
+			if err := tx.Where("task_id = ? AND field_id = ?", taskID, fieldID).Delete(&TaskFieldValue{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		value := TaskFieldValue{
+			TaskID:      taskID,
+			FieldID:     fieldID,
+			Value:       v.Value,
+			NumberValue: v.NumberValue,
+			DateValue:   v.DateValue,
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "task_id"}, {Name: "field_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "number_value", "date_value"}),
+		}).Create(&value).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetch custom field values of tasks keyed by task id and field name
+func getTaskFieldValues(taskIDs []int64) (map[int64]map[string]interface{}, error) {
+	var rows []struct {
+		TaskID int64
+		Name   string
+		Type   string
+		Value  string
+	}
+
+	result := map[int64]map[string]interface{}{}
+	if len(taskIDs) == 0 {
+		return result, nil
+	}
+
+	err := DB.Table("task_field_values").
+		Select("task_field_values.task_id, custom_fields.name, custom_fields.type, task_field_values.value").
+		Joins("JOIN custom_fields ON custom_fields.id = task_field_values.field_id").
+		Where("task_field_values.task_id IN ?", taskIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if result[row.TaskID] == nil {
+			result[row.TaskID] = map[string]interface{}{}
+		}
+		result[row.TaskID][row.Name] = decodeFieldValue(row.Type, row.Value)
+	}
+
+	return result, nil
+}
+
+// attach custom field values to task
+func attachCustomFields(t *models.Task) error {
+	values, err := getTaskFieldValues([]int64{t.ID})
+	if err != nil {
+		return err
+	}
+
+	t.CustomFields = values[t.ID]
+	if t.CustomFields == nil {
+		t.CustomFields = map[string]interface{}{}
+	}
+
+	return nil
+}
+
+// attach custom field values to list of tasks
+func attachCustomFieldsToList(tasks []Task) error {
+	taskIDs := make([]int64, 0, len(tasks))
+	for _, t := range tasks {
+		taskIDs = append(taskIDs, t.ID)
+	}
+
+	values, err := getTaskFieldValues(taskIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		tasks[i].CustomFields = values[tasks[i].ID]
+		if tasks[i].CustomFields == nil {
+			tasks[i].CustomFields = map[string]interface{}{}
+		}
+	}
+
+	return nil
+}
+
+// convert stored value to its json representation
+func decodeFieldValue(fieldType, value string) interface{} {
+	switch fieldType {
+	case models.FieldTypeNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil
+		}
+		return number
+	case models.FieldTypeCheckbox:
+		return value == "true"
+	case models.FieldTypeMultiSelect:
+		selected := []string{}
+		_ = json.Unmarshal([]byte(value), &selected)
+		return selected
+	}
+
+	return value
+}
+
+func toCustomFieldModel(f CustomField) models.CustomField {
+	options := []string{}
+	_ = json.Unmarshal([]byte(f.Options), &options)
+
+	return models.CustomField{
+		ID:      f.ID,
+		Name:    f.Name,
+		Type:    f.Type,
+		Options: options,
+	}
+}
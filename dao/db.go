@@ -3,6 +3,7 @@ package dao
 import (
 	"os"
 	"task_manager/logger"
+	"task_manager/utils"
 	"time"
 
 	"gorm.io/driver/mysql"
@@ -13,21 +14,45 @@ var DB *gorm.DB
 
 // User DB Schema
 type User struct {
-	ID       int64  `gorm:"primaryKey;autoIncrement"`
-	Name     string `gorm:"not null"`
-	MobileNo string `gorm:"not null"`
-	Gender   string `gorm:"not null"`
-	Email    string `gorm:"not null;unique"`
+	ID              int64  `gorm:"primaryKey;autoIncrement"`
+	Name            string `gorm:"not null"`
+	MobileNo        string `gorm:"not null"`
+	Gender          string `gorm:"not null"`
+	Email           string `gorm:"not null;unique"`
+	EmailVerified   bool   `gorm:"not null;default:false"`
+	EmailVerifiedAt *time.Time
+	// the account is hard deleted at this time, no new sessions can be started before
+	DeletionScheduledAt *time.Time `gorm:"index"`
+	// disabled accounts cannot start sessions until an admin enables them again
+	DisabledAt *time.Time
+	// "user" or "admin", admins can use the /admin routes
+	Role      string `gorm:"not null;size:20;default:user;index"`
+	CreatedAt time.Time
 }
 
 // Token DB Schema
 type Token struct {
-	ID           int64     `gorm:"primaryKey;autoIncrement"`
-	RefreshToken string    `gorm:"not null;unique"`
-	UserToken    string    `gorm:"not null;unique"`
-	Timestamp    time.Time `gorm:"not null"`
-	UserID       int64
-	User         User `gorm:"foreignKey:UserID"`
+	ID               int64     `gorm:"primaryKey;autoIncrement"`
+	RefreshTokenHash string    `gorm:"not null;size:64;unique"`
+	UserTokenHash    string    `gorm:"not null;size:64;unique"`
+	Timestamp        time.Time `gorm:"not null"`
+	UserAgent        string
+	IP               string
+	DeviceName       string
+	LastSeenAt       *time.Time
+	FamilyID         string `gorm:"size:64;index"`
+	ClientID         string `gorm:"size:64;index"`
+	UserID           int64
+	User             User `gorm:"foreignKey:UserID"`
+}
+
+// Refresh tokens already rotated, kept to detect reuse
+type RotatedToken struct {
+	ID               int64     `gorm:"primaryKey;autoIncrement"`
+	RefreshTokenHash string    `gorm:"not null;size:64;unique"`
+	FamilyID         string    `gorm:"not null;size:64;index"`
+	RotatedAt        time.Time `gorm:"not null;index"`
+	UserID           int64
 }
 
 // Login DB schema
@@ -50,13 +75,267 @@ type Avatar struct {
 
 // Task DB schema
 type Task struct {
-	ID          int64  `json:"id"`
-	Title       string ` json:"title"`
-	Description string `json:"description"`
-	Completed   string `gorm:"default:false" json:"completed"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	UserID      int64 ` json:"userId"`
+	ID              int64      `json:"id"`
+	Title           string     ` json:"title"`
+	Description     string     `json:"description"`
+	Completed       string     `gorm:"default:false" json:"completed"`
+	Estimate        int        `gorm:"default:0" json:"estimate"`
+	EstimateUnit    string     `gorm:"default:points" json:"estimate_unit"`
+	RemainingEffort int        `gorm:"default:0" json:"remaining_effort"`
+	DueDate         *time.Time `json:"due_date"`
+	Priority        string     `json:"priority"`
+	Tags            string     `json:"tags"`
+	Recurrence      string     `json:"recurrence"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	UserID          int64                  ` json:"userId"`
+	CustomFields    map[string]interface{} `gorm:"-" json:"custom_fields"`
+}
+
+// Custom field definition DB schema
+type CustomField struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement"`
+	Name      string `gorm:"not null;size:100;uniqueIndex:idx_custom_field_user_name"`
+	Type      string `gorm:"not null"`
+	Options   string `gorm:"type:text"`
+	CreatedAt time.Time
+	UserID    int64 `gorm:"uniqueIndex:idx_custom_field_user_name"`
+	User      User  `gorm:"foreignKey:UserID"`
+}
+
+// Custom field value DB schema
+type TaskFieldValue struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement"`
+	TaskID      int64  `gorm:"not null;uniqueIndex:idx_task_field_value"`
+	FieldID     int64  `gorm:"not null;uniqueIndex:idx_task_field_value"`
+	Value       string `gorm:"type:text"`
+	NumberValue *float64
+	DateValue   *time.Time
+}
+
+// Task template DB schema
+type TaskTemplate struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement"`
+	Name      string `gorm:"not null;size:100;uniqueIndex:idx_task_template_user_name"`
+	CreatedAt time.Time
+	UserID    int64              `gorm:"uniqueIndex:idx_task_template_user_name"`
+	User      User               `gorm:"foreignKey:UserID"`
+	Items     []TaskTemplateItem `gorm:"foreignKey:TemplateID"`
+}
+
+// Task template item DB schema
+type TaskTemplateItem struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement"`
+	Title       string `gorm:"not null"`
+	Description string
+	Completed   string `gorm:"default:false"`
+	DayOffset   int    `gorm:"not null;default:0"`
+	Position    int    `gorm:"not null"`
+	TemplateID  int64  `gorm:"index"`
+}
+
+// User preferences DB schema
+type UserPreference struct {
+	ID              int64  `gorm:"primaryKey;autoIncrement"`
+	Timezone        string `gorm:"not null"`
+	WeekStart       string `gorm:"not null"`
+	DateFormat      string `gorm:"not null"`
+	DefaultSort     string `gorm:"not null"`
+	DefaultPageSize int    `gorm:"not null"`
+	UserID          int64  `gorm:"uniqueIndex"`
+	User            User   `gorm:"foreignKey:UserID"`
+}
+
+// Password reset token DB schema
+type PasswordReset struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	TokenHash string    `gorm:"not null;size:64;unique"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+	UserID    int64 `gorm:"index"`
+	User      User  `gorm:"foreignKey:UserID"`
+}
+
+// Previous password hashes of a user, checked so that passwords are not reused
+type PasswordHistory struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement"`
+	PasswordHash string    `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"index"`
+	UserID       int64     `gorm:"index"`
+	User         User      `gorm:"foreignKey:UserID"`
+}
+
+// Email verification token DB schema
+type EmailVerification struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	TokenHash string    `gorm:"not null;size:64;unique"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time `gorm:"index"`
+	UserID    int64     `gorm:"index"`
+	User      User      `gorm:"foreignKey:UserID"`
+}
+
+// Pending change of the email address, applied once the new address is confirmed
+type EmailChange struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	TokenHash string    `gorm:"not null;size:64;unique"`
+	NewEmail  string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+	UserID    int64 `gorm:"index"`
+	User      User  `gorm:"foreignKey:UserID"`
+}
+
+// Passwordless sign in link DB schema, bound to the browser that requested it by a nonce
+type MagicLink struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	TokenHash string    `gorm:"not null;size:64;unique"`
+	NonceHash string    `gorm:"not null;size:64"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	IP        string    `gorm:"size:45;index"`
+	CreatedAt time.Time `gorm:"index"`
+	UserID    int64     `gorm:"index"`
+	User      User      `gorm:"foreignKey:UserID"`
+}
+
+// TOTP two factor DB schema, secret is encrypted at rest
+type TwoFactor struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement"`
+	SecretEnc    string `gorm:"not null"`
+	Enabled      bool   `gorm:"not null;default:false"`
+	LastUsedStep int64  `gorm:"not null;default:0"`
+	ConfirmedAt  *time.Time
+	CreatedAt    time.Time
+	UserID       int64 `gorm:"uniqueIndex"`
+	User         User  `gorm:"foreignKey:UserID"`
+}
+
+// Two factor recovery code DB schema
+type RecoveryCode struct {
+	ID       int64  `gorm:"primaryKey;autoIncrement"`
+	CodeHash string `gorm:"not null;size:64"`
+	UsedAt   *time.Time
+	UserID   int64 `gorm:"index"`
+	User     User  `gorm:"foreignKey:UserID"`
+}
+
+// Failed login attempts DB schema, keyed by account or client ip
+type LoginAttempt struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement"`
+	AttemptKey    string    `gorm:"not null;size:320;unique"`
+	Failures      int       `gorm:"not null;default:0"`
+	LastFailureAt time.Time `gorm:"not null"`
+	LockedUntil   *time.Time
+}
+
+// Account unlock token DB schema
+type AccountUnlock struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	TokenHash string    `gorm:"not null;size:64;unique"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UserID    int64     `gorm:"index"`
+	User      User      `gorm:"foreignKey:UserID"`
+}
+
+// Audit log DB schema
+type AuditLog struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement"`
+	Event     string `gorm:"not null;size:64;index"`
+	UserID    *int64 `gorm:"index"`
+	IP        string
+	Detail    string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+// Personal access token DB schema, only the hash of the token is stored
+type PersonalAccessToken struct {
+	ID         int64  `gorm:"primaryKey;autoIncrement"`
+	Name       string `gorm:"not null;size:100"`
+	TokenHash  string `gorm:"not null;size:64;unique"`
+	Hint       string `gorm:"not null;size:16"`
+	Scopes     string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+	UserID     int64 `gorm:"index"`
+	User       User  `gorm:"foreignKey:UserID"`
+}
+
+// Pending sign in with an external provider, keyed by the hash of the state parameter
+type OIDCState struct {
+	ID              int64     `gorm:"primaryKey;autoIncrement"`
+	StateHash       string    `gorm:"not null;size:64;unique"`
+	Provider        string    `gorm:"not null;size:64"`
+	Nonce           string    `gorm:"not null;size:64"`
+	CodeVerifierEnc string    `gorm:"not null;type:text"`
+	ExpiresAt       time.Time `gorm:"not null;index"`
+	// session mode asked for when the sign in started, the callback is a plain redirect
+	CookieMode bool
+}
+
+// Account of an external provider linked to a user
+type ExternalIdentity struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement"`
+	Provider  string `gorm:"not null;size:64;uniqueIndex:idx_provider_subject"`
+	Subject   string `gorm:"not null;size:255;uniqueIndex:idx_provider_subject"`
+	Email     string
+	CreatedAt time.Time
+	UserID    int64 `gorm:"index"`
+	User      User  `gorm:"foreignKey:UserID"`
+}
+
+// OAuth client registered by a user, only the hash of the secret is stored
+type Client struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement"`
+	ClientID     string `gorm:"not null;size:64;unique"`
+	SecretHash   string `gorm:"size:64"`
+	Name         string `gorm:"not null;size:100"`
+	RedirectURIs string `gorm:"type:text"`
+	GrantTypes   string `gorm:"not null"`
+	Scopes       string `gorm:"not null"`
+	CreatedAt    time.Time
+	UserID       int64 `gorm:"index"`
+	User         User  `gorm:"foreignKey:UserID"`
+}
+
+// OAuth authorization code DB schema, tokens issued for the code share its family
+type AuthorizationCode struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement"`
+	CodeHash      string    `gorm:"not null;size:64;unique"`
+	ClientID      string    `gorm:"not null;size:64;index"`
+	RedirectURI   string    `gorm:"not null;type:text"`
+	Scopes        string    `gorm:"not null"`
+	CodeChallenge string    `gorm:"not null;size:128"`
+	FamilyID      string    `gorm:"not null;size:64"`
+	ExpiresAt     time.Time `gorm:"not null;index"`
+	UsedAt        *time.Time
+	UserID        int64 `gorm:"index"`
+	User          User  `gorm:"foreignKey:UserID"`
+}
+
+// Scopes a user has granted to an OAuth client
+type Consent struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement"`
+	ClientID  string `gorm:"not null;size:64;uniqueIndex:idx_user_client"`
+	Scopes    string `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    int64 `gorm:"not null;uniqueIndex:idx_user_client"`
+	User      User  `gorm:"foreignKey:UserID"`
+}
+
+// Access token signing key DB schema, private keys are stored encrypted
+type SigningKey struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement"`
+	Kid           string    `gorm:"not null;size:64;unique"`
+	Algorithm     string    `gorm:"not null;size:16"`
+	PrivateKeyEnc string    `gorm:"not null;type:text"`
+	CreatedAt     time.Time `gorm:"index"`
+	// the key signs from this time on, nil for keys active since creation
+	ActivatesAt *time.Time
+	ExpiresAt   *time.Time
 }
 
 func InitDB() {
@@ -72,8 +351,69 @@ func InitDB() {
 }
 
 func createTables() {
-	err := DB.AutoMigrate(&User{}, &Login{}, &Token{}, &Avatar{}, &Task{})
+	// accounts created before email verification existed are treated as verified
+	grandfatherUsers := DB.Migrator().HasTable(&User{}) && !DB.Migrator().HasColumn(&User{}, "EmailVerified")
+
+	// tokens used to be stored verbatim
+	if err := migrateTokenHashes(); err != nil {
+		logger.Error("requestID", "could not hash stored tokens", err.Error())
+		return
+	}
+
+	err := DB.AutoMigrate(&User{}, &Login{}, &Token{}, &RotatedToken{}, &Avatar{}, &Task{}, &CustomField{}, &TaskFieldValue{}, &TaskTemplate{}, &TaskTemplateItem{}, &UserPreference{}, &PasswordReset{}, &EmailVerification{}, &TwoFactor{}, &RecoveryCode{}, &LoginAttempt{}, &AccountUnlock{}, &AuditLog{}, &SigningKey{}, &PersonalAccessToken{}, &OIDCState{}, &ExternalIdentity{}, &Client{}, &AuthorizationCode{}, &Consent{}, &MagicLink{}, &PasswordHistory{}, &EmailChange{})
 	if err != nil {
 		logger.Error("requestID", "could not migrate tables", err.Error())
+		return
+	}
+
+	if grandfatherUsers {
+		err = DB.Model(&User{}).Where("1 = 1").Update("email_verified", true).Error
+		if err != nil {
+			logger.Error("requestID", "could not mark existing users as verified", err.Error())
+		}
 	}
 }
+
+// Replace raw tokens stored in the token table with their SHA-256 hashes
+func migrateTokenHashes() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&Token{}) || !migrator.HasColumn(&Token{}, "user_token") {
+		return nil
+	}
+
+	type rawToken struct {
+		ID           int64
+		UserToken    string
+		RefreshToken string
+	}
+
+	var rows []rawToken
+	// a hex SHA-256 hash is 64 characters, a JWT never is
+	err := DB.Table("tokens").Select("id, user_token, refresh_token").
+		Where("CHAR_LENGTH(user_token) != 64 OR CHAR_LENGTH(refresh_token) != 64").Find(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			err := tx.Table("tokens").Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"user_token":    utils.HashToken(row.UserToken),
+				"refresh_token": utils.HashToken(row.RefreshToken),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := migrator.RenameColumn(&Token{}, "user_token", "user_token_hash"); err != nil {
+		return err
+	}
+
+	return migrator.RenameColumn(&Token{}, "refresh_token", "refresh_token_hash")
+}
@@ -0,0 +1,102 @@
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+var ErrEmailInUse = errors.New("email already in use")
+
+// Whether an account already uses the email address
+func IsEmailInUse(email string) (bool, error) {
+	var count int64
+	if err := DB.Model(&Login{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := DB.Model(&User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Save a pending email change, earlier pending changes of the user are replaced
+func SaveEmailChange(change *EmailChange) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", change.UserID).Delete(&EmailChange{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(change).Error
+	})
+}
+
+// Consume an email change token and move User and Login to the new address together.
+// Returns the user and the previous address.
+func ConfirmEmailChange(tokenHash string) (int64, string, string, error) {
+	var uid int64
+	var oldEmail, newEmail string
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var change EmailChange
+		if err := tx.Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).First(&change).Error; err != nil {
+			return ErrInvalidEmailChangeToken
+		}
+
+		// conditional delete so that a token can only be used once under concurrency
+		result := tx.Delete(&change)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrInvalidEmailChangeToken
+		}
+
+		var user User
+		if err := tx.Where("id = ?", change.UserID).First(&user).Error; err != nil {
+			return err
+		}
+
+		//the address may have been taken since the change was requested
+		var taken int64
+		if err := tx.Model(&Login{}).Where("email = ? AND user_id != ?", change.NewEmail, change.UserID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrEmailInUse
+		}
+
+		err := tx.Model(&User{}).Where("id = ?", change.UserID).Updates(map[string]interface{}{
+			"email":             change.NewEmail,
+			"email_verified":    true,
+			"email_verified_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Login{}).Where("user_id = ?", change.UserID).Update("email", change.NewEmail).Error; err != nil {
+			return err
+		}
+
+		//verification links sent to the old address must not verify the new one
+		if err := tx.Where("user_id = ?", change.UserID).Delete(&EmailVerification{}).Error; err != nil {
+			return err
+		}
+
+		uid, oldEmail, newEmail = change.UserID, user.Email, change.NewEmail
+		return nil
+	})
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	return uid, oldEmail, newEmail, nil
+}
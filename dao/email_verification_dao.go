@@ -0,0 +1,82 @@
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// Save hash of an email verification token
+func SaveEmailVerification(uid int64, tokenHash string, expiresAt time.Time) error {
+	verification := EmailVerification{
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		UserID:    uid,
+	}
+
+	return DB.Create(&verification).Error
+}
+
+// Count verification emails sent to user since the given time and fetch the latest send time
+func GetRecentVerifications(uid int64, since time.Time) (int64, *time.Time, error) {
+	var count int64
+	if err := DB.Model(&EmailVerification{}).Where("user_id = ? AND created_at > ?", uid, since).Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var latest EmailVerification
+	err := DB.Where("user_id = ?", uid).Order("created_at DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return count, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return count, &latest.CreatedAt, nil
+}
+
+// Mark the email of the token owner as verified and remove all their verification tokens
+func VerifyEmail(tokenHash string) (int64, error) {
+	var uid int64
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var verification EmailVerification
+		if err := tx.Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).First(&verification).Error; err != nil {
+			return ErrInvalidVerificationToken
+		}
+
+		err := tx.Model(&User{}).Where("id = ?", verification.UserID).Updates(map[string]interface{}{
+			"email_verified":    true,
+			"email_verified_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", verification.UserID).Delete(&EmailVerification{}).Error; err != nil {
+			return err
+		}
+
+		uid = verification.UserID
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return uid, nil
+}
+
+// Fetch whether the user has verified their email and when the account was created
+func GetVerificationStatus(uid int64) (*User, error) {
+	var user User
+	if err := DB.Select("id, email, email_verified, created_at").Where("id = ?", uid).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
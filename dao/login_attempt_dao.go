@@ -0,0 +1,175 @@
+package dao
+
+import (
+	"errors"
+	"task_manager/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidUnlockToken = errors.New("invalid or expired unlock token")
+
+// Fetch failed login attempts for key, nil when there are none
+func GetLoginAttempt(key string) (*LoginAttempt, error) {
+	var attempt LoginAttempt
+	err := DB.Where("attempt_key = ?", key).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// Reserve a login attempt for every key before the credentials are checked. The rows are
+// locked so concurrent attempts are decided one after another, an allowed attempt counts as
+// a failure right away and is released again when it succeeds. Returns the time to wait when
+// the attempt is not allowed. Keys have to be passed in the same order by every caller.
+func ReserveLoginAttempt(keys []string, protection utils.LoginProtection) (time.Duration, error) {
+	var wait time.Duration
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		attempts := make([]LoginAttempt, 0, len(keys))
+
+		for _, key := range keys {
+			//the row has to exist to be locked
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&LoginAttempt{AttemptKey: key, LastFailureAt: now}).Error
+			if err != nil {
+				return err
+			}
+
+			var attempt LoginAttempt
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("attempt_key = ?", key).First(&attempt).Error; err != nil {
+				return err
+			}
+
+			wait = max(wait, protection.RetryAfter(attempt.Failures, attempt.LastFailureAt, attempt.LockedUntil, now))
+			attempts = append(attempts, attempt)
+		}
+		if wait > 0 {
+			return nil
+		}
+
+		for _, attempt := range attempts {
+			//failures older than the window start the count over
+			failures := attempt.Failures + 1
+			if now.Sub(attempt.LastFailureAt) > protection.Window {
+				failures = 1
+			}
+
+			err := tx.Model(&LoginAttempt{}).Where("id = ?", attempt.ID).Updates(map[string]interface{}{
+				"failures":        failures,
+				"last_failure_at": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return wait, nil
+}
+
+// Count a request for key and return the requests made within window including this one.
+// The row is locked so concurrent requests are counted one after another.
+func CountAttempt(key string, window time.Duration) (int, error) {
+	var count int
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&LoginAttempt{AttemptKey: key, LastFailureAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		var attempt LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("attempt_key = ?", key).First(&attempt).Error; err != nil {
+			return err
+		}
+
+		//requests older than the window start the count over
+		count = attempt.Failures + 1
+		if now.Sub(attempt.LastFailureAt) > window {
+			count = 1
+		}
+
+		return tx.Model(&LoginAttempt{}).Where("id = ?", attempt.ID).Updates(map[string]interface{}{
+			"failures":        count,
+			"last_failure_at": now,
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Take back a reserved attempt that did not fail
+func ReleaseLoginAttempt(key string) error {
+	return DB.Model(&LoginAttempt{}).Where("attempt_key = ? AND failures > 0", key).Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+// Lock key until the given time
+func LockLoginAttempt(key string, until time.Time) error {
+	return DB.Model(&LoginAttempt{}).Where("attempt_key = ?", key).Update("locked_until", until).Error
+}
+
+// Remove failed login attempts for key
+func ClearLoginAttempts(key string) error {
+	return DB.Where("attempt_key = ?", key).Delete(&LoginAttempt{}).Error
+}
+
+// Save hash of an account unlock token
+func SaveAccountUnlock(uid int64, tokenHash string, expiresAt time.Time) error {
+	unlock := AccountUnlock{
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		UserID:    uid,
+	}
+
+	return DB.Create(&unlock).Error
+}
+
+// Consume an unlock token and clear the failed attempts of the account key
+func UnlockAccount(tokenHash string) (int64, error) {
+	var uid int64
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var unlock AccountUnlock
+		if err := tx.Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).First(&unlock).Error; err != nil {
+			return ErrInvalidUnlockToken
+		}
+
+		var login Login
+		if err := tx.Where("user_id = ?", unlock.UserID).First(&login).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("attempt_key = ?", utils.AccountAttemptKey(login.Email)).Delete(&LoginAttempt{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", unlock.UserID).Delete(&AccountUnlock{}).Error; err != nil {
+			return err
+		}
+
+		uid = unlock.UserID
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return uid, nil
+}
@@ -0,0 +1,35 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountAttempt(t *testing.T) {
+	useTestDB(t, &LoginAttempt{})
+
+	for want := 1; want <= 3; want++ {
+		count, err := CountAttempt("magic_link_ip:10.0.0.1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("count = %d, want %d", count, want)
+		}
+	}
+
+	//other keys are counted separately
+	if count, err := CountAttempt("magic_link_ip:10.0.0.2", time.Hour); err != nil || count != 1 {
+		t.Errorf("count = %d, %v, want 1", count, err)
+	}
+
+	//requests older than the window are forgotten
+	err := DB.Model(&LoginAttempt{}).Where("attempt_key = ?", "magic_link_ip:10.0.0.1").
+		Update("last_failure_at", time.Now().Add(-2*time.Hour)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := CountAttempt("magic_link_ip:10.0.0.1", time.Hour); err != nil || count != 1 {
+		t.Errorf("count after window = %d, %v, want 1", count, err)
+	}
+}
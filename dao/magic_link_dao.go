@@ -0,0 +1,80 @@
+package dao
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired sign in link")
+var ErrMagicLinkBrowserMismatch = errors.New("sign in link must be opened in the browser that requested it")
+
+// Save hash of a sign in link, earlier unused links of the user are expired. They are kept
+// so that the links sent to the user within an hour can still be counted.
+func SaveMagicLink(link *MagicLink) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&MagicLink{}).Where("user_id = ? AND used_at IS NULL AND expires_at > ?", link.UserID, now).Update("expires_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(link).Error
+	})
+}
+
+// Count sign in links sent to user since the given time and fetch the latest send time
+func GetRecentMagicLinks(uid int64, since time.Time) (int64, *time.Time, error) {
+	var count int64
+	if err := DB.Model(&MagicLink{}).Where("user_id = ? AND created_at > ?", uid, since).Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var latest MagicLink
+	err := DB.Where("user_id = ?", uid).Order("created_at DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return count, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return count, &latest.CreatedAt, nil
+}
+
+// Consume a sign in link opened by the browser holding the nonce. A link opened
+// elsewhere is left unused so it cannot be burnt by whoever intercepted it.
+func ConsumeMagicLink(tokenHash, nonceHash string) (int64, error) {
+	var uid int64
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var link MagicLink
+		now := time.Now()
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).First(&link).Error; err != nil {
+			return ErrInvalidMagicLink
+		}
+
+		if subtle.ConstantTimeCompare([]byte(link.NonceHash), []byte(nonceHash)) != 1 {
+			return ErrMagicLinkBrowserMismatch
+		}
+
+		// conditional update so that a link can only be used once under concurrency
+		result := tx.Model(&MagicLink{}).Where("id = ? AND used_at IS NULL", link.ID).Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrInvalidMagicLink
+		}
+
+		uid = link.UserID
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return uid, nil
+}
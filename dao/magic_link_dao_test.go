@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMagicLinkCap(t *testing.T) {
+	useTestDB(t, &User{}, &MagicLink{})
+
+	user := User{Name: "Jane", Email: "jane@example.com"}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Now().Add(-time.Hour)
+	for i := 1; i <= 5; i++ {
+		link := MagicLink{
+			TokenHash: "token-" + strconv.Itoa(i),
+			NonceHash: "nonce",
+			ExpiresAt: time.Now().Add(15 * time.Minute),
+			UserID:    user.ID,
+		}
+		if err := SaveMagicLink(&link); err != nil {
+			t.Fatal(err)
+		}
+
+		//links that were never clicked still count towards the cap
+		count, lastSent, err := GetRecentMagicLinks(user.ID, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != int64(i) {
+			t.Errorf("count = %d, want %d", count, i)
+		}
+		if lastSent == nil {
+			t.Error("no latest send time")
+		}
+	}
+
+	//only the newest link signs in
+	if _, err := ConsumeMagicLink("token-4", "nonce"); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("earlier link: error = %v, want %v", err, ErrInvalidMagicLink)
+	}
+	uid, err := ConsumeMagicLink("token-5", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid != user.ID {
+		t.Errorf("user = %d, want %d", uid, user.ID)
+	}
+
+	//links older than the window are not counted
+	if err := DB.Model(&MagicLink{}).Where("token_hash = ?", "token-1").Update("created_at", time.Now().Add(-2*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count, _, err := GetRecentMagicLinks(user.ID, since); err != nil || count != 4 {
+		t.Errorf("count = %d, %v, want 4", count, err)
+	}
+}
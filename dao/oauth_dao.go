@@ -0,0 +1,189 @@
+package dao
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidAuthorizationCode = errors.New("invalid or expired authorization code")
+var ErrAuthorizationCodeReused = errors.New("authorization code was already used")
+
+// Save a newly registered OAuth client
+func SaveClient(client *Client) error {
+	return DB.Create(client).Error
+}
+
+// Fetch the OAuth clients registered by user
+func GetClients(uid int64) ([]Client, error) {
+	var clients []Client
+	if err := DB.Where("user_id = ?", uid).Order("created_at DESC").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// Fetch an OAuth client by its client id
+func GetClient(clientId string) (*Client, error) {
+	var client Client
+	if err := DB.Where("client_id = ?", clientId).First(&client).Error; err != nil {
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+// Delete an OAuth client of user along with its tokens, codes and consents
+func DeleteClient(uid, id int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var client Client
+		if err := tx.Where("id = ? AND user_id = ?", id, uid).First(&client).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("client_id = ?", client.ClientID).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ?", client.ClientID).Delete(&AuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ?", client.ClientID).Delete(&Consent{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&client).Error
+	})
+}
+
+// Fetch the consent of user for a client, nil when none was granted
+func GetConsent(uid int64, clientId string) (*Consent, error) {
+	var consent Consent
+	err := DB.Where("user_id = ? AND client_id = ?", uid, clientId).First(&consent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+// Fetch the consents of user, newest first
+func GetConsents(uid int64) ([]Consent, error) {
+	var consents []Consent
+	if err := DB.Where("user_id = ?", uid).Order("updated_at DESC").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+
+	return consents, nil
+}
+
+// Grant scopes to a client, adding to the scopes granted before
+func SaveConsent(uid int64, clientId string, scopes []string) error {
+	existing, err := GetConsent(uid, clientId)
+	if err != nil {
+		return err
+	}
+
+	granted := scopes
+	if existing != nil {
+		granted = strings.Fields(existing.Scopes)
+		for _, scope := range scopes {
+			if !strings.Contains(" "+existing.Scopes+" ", " "+scope+" ") {
+				granted = append(granted, scope)
+			}
+		}
+	}
+
+	consent := Consent{UserID: uid, ClientID: clientId, Scopes: strings.Join(granted, " ")}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(&consent).Error
+}
+
+// Withdraw the consent of user for a client and revoke the tokens issued to it
+func RevokeConsent(uid int64, clientId string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND client_id = ?", uid, clientId).Delete(&Consent{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("user_id = ? AND client_id = ?", uid, clientId).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ? AND client_id = ?", uid, clientId).Delete(&AuthorizationCode{}).Error
+	})
+}
+
+// Save an authorization code, expired codes are cleaned up
+func SaveAuthorizationCode(code *AuthorizationCode) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&AuthorizationCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(code).Error
+	})
+}
+
+// Mark an authorization code as used. A code presented a second time is returned
+// with ErrAuthorizationCodeReused so the tokens issued for it can be revoked.
+func ConsumeAuthorizationCode(codeHash string) (*AuthorizationCode, error) {
+	var code AuthorizationCode
+	if err := DB.Where("code_hash = ?", codeHash).First(&code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAuthorizationCode
+		}
+		return nil, err
+	}
+
+	if code.UsedAt != nil {
+		return &code, ErrAuthorizationCodeReused
+	}
+	if time.Now().After(code.ExpiresAt) {
+		return nil, ErrInvalidAuthorizationCode
+	}
+
+	result := DB.Model(&AuthorizationCode{}).Where("id = ? AND used_at IS NULL", code.ID).Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return &code, ErrAuthorizationCodeReused
+	}
+
+	return &code, nil
+}
+
+// Checks whether a token issued to the client is still stored, i.e. not revoked
+func IsClientTokenActive(clientId, tokenHash string, refresh bool) (bool, error) {
+	column := "user_token_hash"
+	if refresh {
+		column = "refresh_token_hash"
+	}
+
+	var count int64
+	err := DB.Model(&Token{}).Where("client_id = ? AND "+column+" = ?", clientId, tokenHash).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Revoke the token pair of a client that contains the token
+func RevokeClientToken(clientId, tokenHash string) error {
+	return DB.Where("client_id = ? AND (user_token_hash = ? OR refresh_token_hash = ?)", clientId, tokenHash, tokenHash).
+		Delete(&Token{}).Error
+}
@@ -0,0 +1,161 @@
+package dao
+
+import (
+	"errors"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidOIDCState = errors.New("invalid or expired sign in state")
+var ErrUnverifiedExternalEmail = errors.New("email of the external account is not verified")
+
+// How a sign in with an external provider was matched to a user
+const (
+	ExternalIdentityExisting    = "existing"
+	ExternalIdentityLinked      = "linked"
+	ExternalIdentityProvisioned = "provisioned"
+)
+
+// Save a pending sign in, expired ones are cleaned up
+func SaveOIDCState(state *OIDCState) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&OIDCState{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(state).Error
+	})
+}
+
+// Fetch and remove a pending sign in so the state can only be used once
+func ConsumeOIDCState(stateHash, provider string) (*OIDCState, error) {
+	var state OIDCState
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("state_hash = ? AND provider = ? AND expires_at > ?", stateHash, provider, time.Now()).First(&state).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidOIDCState
+		}
+		if err != nil {
+			return err
+		}
+
+		result := tx.Delete(&state)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrInvalidOIDCState
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// Find the user of an external account. Unknown accounts are linked to the user
+// with the same verified email, or a new user is provisioned.
+func SignInExternalIdentity(provider string, claims models.OIDCClaims) (int64, string, error) {
+	var uid int64
+	outcome := ExternalIdentityExisting
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var identity ExternalIdentity
+		err := tx.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+		if err == nil {
+			uid = identity.UserID
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		//an unverified email could be used to take over the matching account
+		if claims.Email == "" || !claims.EmailVerified {
+			return ErrUnverifiedExternalEmail
+		}
+
+		now := time.Now()
+		var user User
+		err = tx.Where("email = ?", claims.Email).First(&user).Error
+		switch {
+		case err == nil:
+			outcome = ExternalIdentityLinked
+			if !user.EmailVerified {
+				err = tx.Model(&user).Updates(map[string]interface{}{"email_verified": true, "email_verified_at": now}).Error
+				if err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			outcome = ExternalIdentityProvisioned
+			user, err = provisionExternalUser(tx, claims, now)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		uid = user.ID
+		identity = ExternalIdentity{
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+			UserID:   user.ID,
+		}
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	return uid, outcome, nil
+}
+
+// Create the user and login of a new external account. The login gets an unusable
+// random password until the user sets one through password reset.
+func provisionExternalUser(tx *gorm.DB, claims models.OIDCClaims, now time.Time) (User, error) {
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+
+	user := User{
+		Name:            name,
+		Email:           claims.Email,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return User{}, err
+	}
+
+	password, err := utils.GenerateRandomToken()
+	if err != nil {
+		return User{}, err
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	login := Login{
+		Email:    claims.Email,
+		Password: hashedPassword,
+		UserID:   user.ID,
+	}
+	if err := tx.Create(&login).Error; err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,153 @@
+package dao
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"task_manager/models"
+	"testing"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlite driver with the MySQL functions the queries use
+const testDriver = "sqlite3_mysql_functions"
+
+func init() {
+	sql.Register(testDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("JSON_CONTAINS", jsonContains, true)
+		},
+	})
+}
+
+// JSON_CONTAINS of MySQL for scalar candidates: an array contains its elements, a scalar itself
+func jsonContains(target, candidate string) (bool, error) {
+	var doc, value interface{}
+	if err := json.Unmarshal([]byte(target), &doc); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(candidate), &value); err != nil {
+		return false, err
+	}
+
+	if elements, ok := doc.([]interface{}); ok {
+		for _, element := range elements {
+			if reflect.DeepEqual(element, value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return reflect.DeepEqual(doc, value), nil
+}
+
+// point DB at an empty in memory database with the given tables
+func useTestDB(t *testing.T, tables ...interface{}) {
+	t.Helper()
+
+	dialector := sqlite.Dialector{DriverName: testDriver, DSN: "file::memory:"}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := DB
+	DB = db
+	t.Cleanup(func() { DB = previous })
+}
+
+func TestSignInExternalIdentity(t *testing.T) {
+	useTestDB(t, &User{}, &Login{}, &ExternalIdentity{})
+
+	existing := User{Name: "Jane", Email: "jane@example.com"}
+	if err := DB.Create(&existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		claims      models.OIDCClaims
+		wantOutcome string
+		wantErr     error
+		// expected user, 0 for a newly provisioned one
+		wantUser int64
+	}{
+		{
+			name:    "unverified email is rejected",
+			claims:  models.OIDCClaims{Subject: "sub-unverified", Email: "jane@example.com"},
+			wantErr: ErrUnverifiedExternalEmail,
+		},
+		{
+			name:        "verified email links the existing user",
+			claims:      models.OIDCClaims{Subject: "sub-jane", Email: "jane@example.com", EmailVerified: true},
+			wantOutcome: ExternalIdentityLinked,
+			wantUser:    existing.ID,
+		},
+		{
+			name:        "linked identity signs in the same user",
+			claims:      models.OIDCClaims{Subject: "sub-jane", Email: "other@example.com"},
+			wantOutcome: ExternalIdentityExisting,
+			wantUser:    existing.ID,
+		},
+		{
+			name:        "unknown email provisions a user",
+			claims:      models.OIDCClaims{Subject: "sub-john", Email: "john@example.com", EmailVerified: true, Name: "John"},
+			wantOutcome: ExternalIdentityProvisioned,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid, outcome, err := SignInExternalIdentity("mock", tt.claims)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if outcome != tt.wantOutcome {
+				t.Errorf("outcome = %q, want %q", outcome, tt.wantOutcome)
+			}
+			if tt.wantUser != 0 && uid != tt.wantUser {
+				t.Errorf("user = %d, want %d", uid, tt.wantUser)
+			}
+
+			var user User
+			if err := DB.Where("id = ?", uid).First(&user).Error; err != nil {
+				t.Fatal(err)
+			}
+			if !user.EmailVerified {
+				t.Error("email of the signed in user is not verified")
+			}
+		})
+	}
+
+	var identities int64
+	if err := DB.Model(&ExternalIdentity{}).Where("subject = ?", "sub-unverified").Count(&identities).Error; err != nil {
+		t.Fatal(err)
+	}
+	if identities != 0 {
+		t.Error("identity with an unverified email was linked")
+	}
+
+	var login Login
+	if err := DB.Where("email = ?", "john@example.com").First(&login).Error; err != nil {
+		t.Fatalf("provisioned user has no login: %v", err)
+	}
+	if login.Password == "" {
+		t.Error("provisioned login has no password hash")
+	}
+}
@@ -0,0 +1,48 @@
+package dao
+
+import (
+	"gorm.io/gorm"
+)
+
+// Fetch the current password hash of user followed by the last limit previous ones
+func GetPasswordHistory(uid int64, limit int) ([]string, error) {
+	var login Login
+	if err := DB.Where("user_id = ?", uid).First(&login).Error; err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	err := DB.Model(&PasswordHistory{}).Where("user_id = ?", uid).Order("created_at DESC, id DESC").Limit(limit).Pluck("password_hash", &hashes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{login.Password}, hashes...), nil
+}
+
+// Move the current password of user into the history, keeping the last historySize entries
+func recordPasswordHistory(tx *gorm.DB, uid int64, historySize int) error {
+	var login Login
+	if err := tx.Where("user_id = ?", uid).First(&login).Error; err != nil {
+		return err
+	}
+
+	entry := PasswordHistory{
+		PasswordHash: login.Password,
+		UserID:       uid,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	var stale []int64
+	err := tx.Model(&PasswordHistory{}).Where("user_id = ?", uid).Order("created_at DESC, id DESC").Offset(historySize).Pluck("id", &stale).Error
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	return tx.Where("id IN ?", stale).Delete(&PasswordHistory{}).Error
+}
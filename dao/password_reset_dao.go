@@ -0,0 +1,90 @@
+package dao
+
+import (
+	"errors"
+	"task_manager/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// Fetch the login of user by email
+func GetLoginByEmail(email string) (*models.Login, error) {
+	var login models.Login
+	if err := DB.Where("email = ?", email).First(&login).Error; err != nil {
+		return nil, err
+	}
+
+	return &login, nil
+}
+
+// Save hash of a password reset token, earlier unused tokens of the user are invalidated
+func SavePasswordReset(uid int64, tokenHash string, expiresAt time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", uid).Delete(&PasswordReset{}).Error; err != nil {
+			return err
+		}
+
+		reset := PasswordReset{
+			TokenHash: tokenHash,
+			ExpiresAt: expiresAt,
+			UserID:    uid,
+		}
+		return tx.Create(&reset).Error
+	})
+}
+
+// Fetch the user of an unused password reset token
+func GetPasswordResetUser(tokenHash string) (int64, error) {
+	var reset PasswordReset
+	if err := DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, time.Now()).First(&reset).Error; err != nil {
+		return 0, ErrInvalidResetToken
+	}
+
+	return reset.UserID, nil
+}
+
+// Consume a password reset token, update the password, sign out all sessions and revoke personal access tokens
+func ResetPassword(tokenHash, hashedPassword string, historySize int) (int64, error) {
+	var uid int64
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var reset PasswordReset
+		now := time.Now()
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).First(&reset).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		// conditional update so that a token can only be used once under concurrency
+		result := tx.Model(&PasswordReset{}).Where("id = ? AND used_at IS NULL", reset.ID).Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrInvalidResetToken
+		}
+
+		if err := recordPasswordHistory(tx, reset.UserID, historySize); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Login{}).Where("user_id = ?", reset.UserID).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+
+		//personal access tokens go as well, the reset may follow a compromise
+		if err := revokeAllAccess(tx, reset.UserID); err != nil {
+			return err
+		}
+
+		uid = reset.UserID
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return uid, nil
+}
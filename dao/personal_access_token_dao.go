@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Save a newly created personal access token
+func SavePersonalAccessToken(t *PersonalAccessToken) error {
+	return DB.Create(t).Error
+}
+
+// Fetch all personal access tokens of user, newest first
+func GetPersonalAccessTokens(uid int64) ([]PersonalAccessToken, error) {
+	var tokens []PersonalAccessToken
+	if err := DB.Where("user_id = ?", uid).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Fetch an unexpired personal access token by the hash of its value
+func GetPersonalAccessToken(tokenHash string) (*PersonalAccessToken, error) {
+	var token PersonalAccessToken
+	//tokens of disabled accounts stay stored but cannot be used
+	disabled := DB.Model(&User{}).Select("id").Where("disabled_at IS NOT NULL")
+	err := DB.Where("token_hash = ? AND (expires_at IS NULL OR expires_at > ?) AND user_id NOT IN (?)", tokenHash, time.Now(), disabled).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Revoke a personal access token of user
+func DeletePersonalAccessToken(uid, tokenId int64) error {
+	result := DB.Where("id = ? AND user_id = ?", tokenId, uid).Delete(&PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Record token usage, skipped when it was recorded within the interval
+func TouchPersonalAccessToken(id int64, interval time.Duration) error {
+	now := time.Now()
+	return DB.Model(&PersonalAccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-interval)).
+		Update("last_used_at", now).Error
+}
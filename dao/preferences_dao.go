@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"errors"
+	"task_manager/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// preferences used until the user saves their own
+var defaultPreferences = models.UserPreferences{
+	Timezone:        "UTC",
+	WeekStart:       "monday",
+	DateFormat:      "YYYY-MM-DD",
+	DefaultSort:     "asc",
+	DefaultPageSize: 5,
+}
+
+// Fetch user preferences, falls back to defaults when none are saved
+func GetPreferences(uid int64) (*models.UserPreferences, error) {
+	var pref UserPreference
+	err := DB.Where("user_id = ?", uid).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		prefs := defaultPreferences
+		return &prefs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserPreferences{
+		Timezone:        pref.Timezone,
+		WeekStart:       pref.WeekStart,
+		DateFormat:      pref.DateFormat,
+		DefaultSort:     pref.DefaultSort,
+		DefaultPageSize: pref.DefaultPageSize,
+	}, nil
+}
+
+// Save user preferences in DB
+func SavePreferences(uid int64, prefs *models.UserPreferences) error {
+	pref := UserPreference{
+		Timezone:        prefs.Timezone,
+		WeekStart:       prefs.WeekStart,
+		DateFormat:      prefs.DateFormat,
+		DefaultSort:     prefs.DefaultSort,
+		DefaultPageSize: prefs.DefaultPageSize,
+		UserID:          uid,
+	}
+
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"timezone", "week_start", "date_format", "default_sort", "default_page_size"}),
+	}).Create(&pref).Error
+}
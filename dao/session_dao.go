@@ -0,0 +1,156 @@
+package dao
+
+import (
+	"errors"
+	"task_manager/models"
+	"task_manager/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Fetch all sessions of user, most recently used first
+func GetSessions(uid int64) ([]Token, error) {
+	var tokens []Token
+	err := DB.Select("id, timestamp, user_agent, ip, device_name, last_seen_at, client_id, user_token_hash, user_id").
+		Where("user_id = ?", uid).Order("last_seen_at DESC").Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete a single session of user
+func DeleteSession(uid, sessionId int64) error {
+	result := DB.Where("id = ? AND user_id = ?", sessionId, uid).Delete(&Token{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Record session activity, skipped when it was recorded within the interval
+func TouchSession(id int64, interval time.Duration) error {
+	now := time.Now()
+	return DB.Model(&Token{}).
+		Where("id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)", id, now.Add(-interval)).
+		Update("last_seen_at", now).Error
+}
+
+// Fetch the session of a refresh token
+func GetSessionByRefreshToken(refreshToken string) (*Token, error) {
+	var token Token
+	if err := DB.Where("refresh_token_hash = ?", utils.HashToken(refreshToken)).First(&token).Error; err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Device metadata of a stored session
+func SessionInfoOf(t *Token) models.SessionInfo {
+	return models.SessionInfo{
+		UserAgent:  t.UserAgent,
+		IP:         t.IP,
+		DeviceName: t.DeviceName,
+		FamilyID:   t.FamilyID,
+		ClientID:   t.ClientID,
+	}
+}
+
+// Rotate a refresh token within its family. The old token is recorded as rotated so
+// that presenting it again can be detected as reuse. Returns false when the old token
+// was already rotated concurrently.
+func RotateRefreshToken(old *Token, userToken, refreshToken string, session models.SessionInfo, retention time.Duration) (bool, error) {
+	rotated := false
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND refresh_token_hash = ?", old.ID, old.RefreshTokenHash).Delete(&Token{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return nil
+		}
+
+		familyID, err := newFamilyID(old.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		record := RotatedToken{
+			RefreshTokenHash: old.RefreshTokenHash,
+			FamilyID:         familyID,
+			RotatedAt:        now,
+			UserID:           old.UserID,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+
+		token := Token{
+			UserTokenHash:    utils.HashToken(userToken),
+			RefreshTokenHash: utils.HashToken(refreshToken),
+			Timestamp:        old.Timestamp,
+			UserAgent:        session.UserAgent,
+			IP:               session.IP,
+			DeviceName:       session.DeviceName,
+			LastSeenAt:       &now,
+			FamilyID:         familyID,
+			ClientID:         old.ClientID,
+			UserID:           old.UserID,
+		}
+		if err := tx.Create(&token).Error; err != nil {
+			return err
+		}
+
+		// rotated tokens past the refresh token lifetime fail verification anyway
+		if err := tx.Where("rotated_at < ?", now.Add(-retention)).Delete(&RotatedToken{}).Error; err != nil {
+			return err
+		}
+
+		rotated = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rotated, nil
+}
+
+// Find the family of an already rotated refresh token, nil when it was never rotated
+func GetRotatedToken(refreshToken string) (*RotatedToken, error) {
+	var rotated RotatedToken
+	err := DB.Where("refresh_token_hash = ?", utils.HashToken(refreshToken)).First(&rotated).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rotated, nil
+}
+
+// Revoke every session of a refresh token family
+func RevokeTokenFamily(familyID string) (int64, error) {
+	result := DB.Where("family_id = ?", familyID).Delete(&Token{})
+	return result.RowsAffected, result.Error
+}
+
+// keep the given family or start a new one
+func newFamilyID(familyID string) (string, error) {
+	if familyID != "" {
+		return familyID, nil
+	}
+
+	return utils.GenerateRandomToken()
+}
@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Fetch the signing keys still valid for verification, newest first
+func GetSigningKeys() ([]SigningKey, error) {
+	var keys []SigningKey
+	err := DB.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at DESC, id DESC").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Add a new signing key. Previous keys keep verifying tokens for the retention period
+// after the new key activates.
+func RotateSigningKey(key *SigningKey, retention time.Duration) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		activatesAt := now
+		if key.ActivatesAt != nil {
+			activatesAt = *key.ActivatesAt
+		}
+
+		err := tx.Model(&SigningKey{}).Where("expires_at IS NULL").Update("expires_at", activatesAt.Add(retention)).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("expires_at <= ?", now).Delete(&SigningKey{}).Error
+	})
+}
@@ -1,19 +1,23 @@
 package dao
 
 import (
+	"encoding/json"
+	"sort"
 	"task_manager/models"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-// save task in db
-func SaveTask(t *models.Task) error {
-	
-	result := DB.Create(&t)
-	if result.Error != nil {
-		return result.Error
-	}
+// save task in db along with its custom field values
+func SaveTask(t *models.Task, values map[int64]*models.CustomFieldValue) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
 
-	return nil
+		return saveTaskFieldValues(tx, t.ID, values)
+	})
 }
 
 // fetch rask by id
@@ -24,24 +28,71 @@ func GetTaskByID(id, userId int64) (*models.Task, error) {
 		return &task, result.Error
 	}
 
+	if err := attachCustomFields(&task); err != nil {
+		return &task, err
+	}
+
 	return &task, nil
 }
 
 // fetch all tasks using filters
-func GetTasksWithFilters(userId int64, sortOrder, completed string, limit, offset int) ([]Task, int64, error) {
+func GetTasksWithFilters(userId int64, sortOrder, completed string, due models.DueRange, fieldFilters []models.CustomFieldFilter, sortField *models.CustomField, limit, offset int) ([]Task, int64, error) {
 	var tasks []Task
 	var totalTasks int64
 
 	// Start building the query
-	query := DB.Order("created_at "+sortOrder).Where("user_id = ?", userId)
+	query := DB.Model(&Task{}).Where("tasks.user_id = ?", userId)
 
 	// Apply the Completed filter if provided
 	if completed != "" {
-		query = query.Where("completed = ?", completed)
+		query = query.Where("tasks.completed = ?", completed)
+	}
+
+	// Apply the due date range if provided
+	if due.From != nil {
+		query = query.Where("tasks.due_date >= ?", *due.From)
+	}
+	if due.Before != nil {
+		query = query.Where("tasks.due_date < ?", *due.Before)
+	}
+
+	// Apply the custom field filters if provided
+	for _, filter := range fieldFilters {
+		values := DB.Model(&TaskFieldValue{}).Select("task_id").Where("field_id = ?", filter.Field.ID)
+		switch {
+		case filter.Field.Type == models.FieldTypeMultiSelect:
+			// selected options are stored as a json array, match the exact element
+			option, _ := json.Marshal(filter.Value.Value)
+			values = values.Where("JSON_CONTAINS(value, ?)", string(option))
+		case filter.Value.NumberValue != nil:
+			values = values.Where("number_value = ?", *filter.Value.NumberValue)
+		case filter.Value.DateValue != nil:
+			values = values.Where("date_value = ?", *filter.Value.DateValue)
+		default:
+			values = values.Where("value = ?", filter.Value.Value)
+		}
+		query = query.Where("tasks.id IN (?)", values)
 	}
 
 	// Count the total number of tasks (without limit/offset)
-	query.Model(&Task{}).Count(&totalTasks)
+	query.Count(&totalTasks)
+
+	// Sort by custom field if provided, tasks without a value come last
+	if sortField != nil {
+		column := "sort_value.value"
+		switch sortField.Type {
+		case models.FieldTypeNumber, models.FieldTypeCheckbox:
+			column = "sort_value.number_value"
+		case models.FieldTypeDate:
+			column = "sort_value.date_value"
+		}
+
+		query = query.Select("tasks.*").
+			Joins("LEFT JOIN task_field_values sort_value ON sort_value.task_id = tasks.id AND sort_value.field_id = ?", sortField.ID).
+			Order(column + " IS NULL").
+			Order(column + " " + sortOrder)
+	}
+	query = query.Order("tasks.created_at " + sortOrder)
 
 	// Apply pagination
 	query = query.Limit(limit).Offset(offset)
@@ -52,25 +103,100 @@ func GetTasksWithFilters(userId int64, sortOrder, completed string, limit, offse
 		return nil, 0, result.Error
 	}
 
+	if err := attachCustomFieldsToList(tasks); err != nil {
+		return nil, 0, err
+	}
+
 	return tasks, totalTasks, nil
 }
 
-// update task in db
-func Update(t *models.Task) error {
-	result := DB.Model(&Task{}).Where("id = ?", t.ID).Updates(Task{Completed: t.Completed, UpdatedAt: time.Now()})
+// update task in db along with its custom field values
+func Update(t *models.Task, values map[int64]*models.CustomFieldValue) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Task{}).Where("id = ?", t.ID).Updates(map[string]interface{}{
+			"completed":        t.Completed,
+			"estimate":         t.Estimate,
+			"estimate_unit":    t.EstimateUnit,
+			"remaining_effort": t.RemainingEffort,
+			"updated_at":       time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return saveTaskFieldValues(tx, t.ID, values)
+	})
+}
+
+// delete task in db along with its custom field values
+func Delete(t *models.Task) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", t.ID).Delete(&TaskFieldValue{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(t).Error
+	})
+}
+
+// aggregate estimated and remaining effort of user tasks, weeks start on weekStart in location
+func GetTaskSummary(userId int64, location *time.Location, weekStart time.Weekday) (*models.TaskSummary, error) {
+	var tasks []Task
+	result := DB.Select("completed, estimate, estimate_unit, remaining_effort, created_at").Where("user_id = ?", userId).Find(&tasks)
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
 	}
 
-	return result.Error
+	summary := models.TaskSummary{
+		ByState: map[string]models.EffortTotals{"completed": {}, "pending": {}},
+		ByWeek:  []models.WeeklyEffort{},
+	}
+	weeks := map[string]*models.WeeklyEffort{}
+
+	for _, t := range tasks {
+		addEffort(&summary.Total, t)
+
+		state := "pending"
+		if t.Completed == "true" {
+			state = "completed"
+		}
+		totals := summary.ByState[state]
+		addEffort(&totals, t)
+		summary.ByState[state] = totals
+
+		// weeks are keyed by the date they start on in the preferred timezone
+		created := t.CreatedAt.In(location)
+		day := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, location)
+		offset := (int(day.Weekday()) - int(weekStart) + 7) % 7
+		start := day.AddDate(0, 0, -offset)
+		key := start.Format(time.DateOnly)
+		weekly, ok := weeks[key]
+		if !ok {
+			weekly = &models.WeeklyEffort{Week: key, WeekStart: start}
+			weeks[key] = weekly
+		}
+		addEffort(&weekly.EffortTotals, t)
+	}
+
+	for _, weekly := range weeks {
+		summary.ByWeek = append(summary.ByWeek, *weekly)
+	}
+	sort.Slice(summary.ByWeek, func(i, j int) bool {
+		return summary.ByWeek[i].WeekStart.Before(summary.ByWeek[j].WeekStart)
+	})
+
+	return &summary, nil
 }
 
-// delete task in db
-func Delete(t *models.Task) error {
-	result := DB.Delete(t)
-	if result.Error != nil {
-		return result.Error
+// add task effort to the totals according to its estimate unit
+func addEffort(totals *models.EffortTotals, t Task) {
+	totals.Tasks++
+	if t.EstimateUnit == "minutes" {
+		totals.EstimatedMinutes += t.Estimate
+		totals.RemainingMinutes += t.RemainingEffort
+		return
 	}
 
-	return nil
+	totals.EstimatedPoints += t.Estimate
+	totals.RemainingPoints += t.RemainingEffort
 }
@@ -0,0 +1,134 @@
+package dao
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"task_manager/models"
+	"testing"
+	"time"
+)
+
+func TestGetTaskSummaryWeeks(t *testing.T) {
+	useTestDB(t, &Task{})
+
+	// a Sunday evening in New York is already Monday in UTC
+	created := []time.Time{
+		time.Date(2024, time.May, 13, 1, 0, 0, 0, time.UTC),
+		time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, time.May, 19, 12, 0, 0, 0, time.UTC),
+	}
+	for _, at := range created {
+		task := Task{UserID: 1, Completed: "false", Estimate: 2, EstimateUnit: "points", CreatedAt: at}
+		if err := DB.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		location  *time.Location
+		weekStart time.Weekday
+		// tasks per week keyed by the first day of the week
+		want map[string]int
+	}{
+		{name: "monday weeks in utc", location: time.UTC, weekStart: time.Monday, want: map[string]int{"2024-05-13": 3}},
+		{name: "sunday weeks in utc", location: time.UTC, weekStart: time.Sunday, want: map[string]int{"2024-05-12": 2, "2024-05-19": 1}},
+		{name: "monday weeks in new york", location: newYork, weekStart: time.Monday, want: map[string]int{"2024-05-06": 1, "2024-05-13": 2}},
+		{name: "saturday weeks in new york", location: newYork, weekStart: time.Saturday, want: map[string]int{"2024-05-11": 2, "2024-05-18": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := GetTaskSummary(1, tt.location, tt.weekStart)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Total.Tasks != len(created) {
+				t.Errorf("total tasks = %d, want %d", summary.Total.Tasks, len(created))
+			}
+
+			got := map[string]int{}
+			for _, week := range summary.ByWeek {
+				got[week.Week] = week.Tasks
+				if week.WeekStart.Weekday() != tt.weekStart || week.WeekStart.Location() != tt.location {
+					t.Errorf("week %s starts %v", week.Week, week.WeekStart)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("weeks = %v, want %v", got, tt.want)
+			}
+			for week, tasks := range tt.want {
+				if got[week] != tasks {
+					t.Errorf("weeks = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTasksWithFiltersMultiSelect(t *testing.T) {
+	useTestDB(t, &Task{}, &CustomField{}, &TaskFieldValue{})
+
+	field := CustomField{Name: "labels", Type: models.FieldTypeMultiSelect, Options: `["bug","bug%","_ug","say \"hi\""]`, UserID: 1}
+	if err := DB.Create(&field).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// selected options of each task, stored the way ParseCustomFieldValue encodes them
+	selected := map[string][]string{
+		"plain":   {"bug"},
+		"percent": {"bug%"},
+		"wild":    {"_ug"},
+		"quoted":  {`say "hi"`, "bug"},
+		"none":    {},
+	}
+	for title, options := range selected {
+		task := Task{Title: title, UserID: 1}
+		if err := DB.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := json.Marshal(options)
+		if err := DB.Create(&TaskFieldValue{TaskID: task.ID, FieldID: field.ID, Value: string(encoded)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		option string
+		want   []string
+	}{
+		{option: "bug", want: []string{"plain", "quoted"}},
+		// LIKE metacharacters are matched literally
+		{option: "bug%", want: []string{"percent"}},
+		{option: "_ug", want: []string{"wild"}},
+		{option: `say "hi"`, want: []string{"quoted"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.option, func(t *testing.T) {
+			filters := []models.CustomFieldFilter{{
+				Field: models.CustomField{ID: field.ID, Name: field.Name, Type: field.Type},
+				Value: models.CustomFieldValue{Value: tt.option},
+			}}
+			tasks, total, err := GetTasksWithFilters(1, "asc", "", models.DueRange{}, filters, nil, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, task := range tasks {
+				got = append(got, task.Title)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("tasks = %v (total %d), want %v", got, total, tt.want)
+			}
+		})
+	}
+}
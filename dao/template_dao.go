@@ -0,0 +1,110 @@
+package dao
+
+import (
+	"task_manager/models"
+
+	"gorm.io/gorm"
+)
+
+// save task template with its items in db
+func SaveTemplate(uid int64, t *models.TaskTemplate) error {
+	template := TaskTemplate{
+		Name:   t.Name,
+		UserID: uid,
+	}
+	for i, item := range t.Items {
+		template.Items = append(template.Items, TaskTemplateItem{
+			Title:       item.Title,
+			Description: item.Description,
+			Completed:   item.Completed,
+			DayOffset:   item.DayOffset,
+			Position:    i,
+		})
+	}
+
+	if err := DB.Create(&template).Error; err != nil {
+		return err
+	}
+
+	t.ID = template.ID
+	return nil
+}
+
+// fetch all task templates of user
+func GetTemplates(uid int64) ([]models.TaskTemplate, error) {
+	var templates []TaskTemplate
+	err := DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("user_id = ?", uid).Order("id").Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.TaskTemplate, 0, len(templates))
+	for _, t := range templates {
+		result = append(result, toTemplateModel(t))
+	}
+
+	return result, nil
+}
+
+// fetch task template of user by id
+func GetTemplateByID(id, uid int64) (*models.TaskTemplate, error) {
+	var template TaskTemplate
+	err := DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("id = ? AND user_id = ?", id, uid).First(&template).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := toTemplateModel(template)
+	return &result, nil
+}
+
+// delete task template with its items
+func DeleteTemplate(id, uid int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var template TaskTemplate
+		if err := tx.Where("id = ? AND user_id = ?", id, uid).First(&template).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("template_id = ?", template.ID).Delete(&TaskTemplateItem{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&template).Error
+	})
+}
+
+// save all tasks in a single transaction
+func SaveTasks(tasks []models.Task) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for i := range tasks {
+			if err := tx.Create(&tasks[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func toTemplateModel(t TaskTemplate) models.TaskTemplate {
+	template := models.TaskTemplate{
+		ID:    t.ID,
+		Name:  t.Name,
+		Items: make([]models.TaskTemplateItem, 0, len(t.Items)),
+	}
+	for _, item := range t.Items {
+		template.Items = append(template.Items, models.TaskTemplateItem{
+			Title:       item.Title,
+			Description: item.Description,
+			Completed:   item.Completed,
+			DayOffset:   item.DayOffset,
+		})
+	}
+
+	return template
+}
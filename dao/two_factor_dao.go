@@ -0,0 +1,106 @@
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Fetch two factor settings of user
+func GetTwoFactor(uid int64) (*TwoFactor, error) {
+	var twoFactor TwoFactor
+	if err := DB.Where("user_id = ?", uid).First(&twoFactor).Error; err != nil {
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+// Check whether user has confirmed two factor authentication
+func IsTwoFactorEnabled(uid int64) (bool, error) {
+	twoFactor, err := GetTwoFactor(uid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return twoFactor.Enabled, nil
+}
+
+// Save a pending two factor secret, replacing any unconfirmed one
+func SavePendingTwoFactor(uid int64, secretEnc string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND enabled = ?", uid, false).Delete(&TwoFactor{}).Error; err != nil {
+			return err
+		}
+
+		twoFactor := TwoFactor{
+			SecretEnc: secretEnc,
+			UserID:    uid,
+		}
+		return tx.Create(&twoFactor).Error
+	})
+}
+
+// Enable two factor and replace recovery codes with the given hashes
+func EnableTwoFactor(uid, step int64, codeHashes []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&TwoFactor{}).Where("user_id = ?", uid).Updates(map[string]interface{}{
+			"enabled":        true,
+			"last_used_step": step,
+			"confirmed_at":   time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return replaceRecoveryCodes(tx, uid, codeHashes)
+	})
+}
+
+// Disable two factor and remove recovery codes
+func DisableTwoFactor(uid int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", uid).Delete(&TwoFactor{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", uid).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// Record the time step of an accepted code, fails if the step was already used
+func UseTwoFactorStep(uid, step int64) (bool, error) {
+	result := DB.Model(&TwoFactor{}).Where("user_id = ? AND last_used_step < ?", uid, step).Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// Consume an unused recovery code
+func UseRecoveryCode(uid int64, codeHash string) (bool, error) {
+	result := DB.Model(&RecoveryCode{}).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", uid, codeHash).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, uid int64, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", uid).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, RecoveryCode{CodeHash: hash, UserID: uid})
+	}
+
+	return tx.Create(&codes).Error
+}
@@ -0,0 +1,40 @@
+package dao
+
+import "testing"
+
+func TestUseTwoFactorStep(t *testing.T) {
+	useTestDB(t, &TwoFactor{})
+
+	if err := DB.Create(&TwoFactor{SecretEnc: "secret", Enabled: true, UserID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		step      int64
+		wantFresh bool
+	}{
+		{name: "first code", step: 100, wantFresh: true},
+		{name: "replayed code", step: 100},
+		{name: "earlier code within the skew", step: 99},
+		{name: "next code", step: 101, wantFresh: true},
+		{name: "replay of the next code", step: 101},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fresh, err := UseTwoFactorStep(1, tt.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fresh != tt.wantFresh {
+				t.Errorf("fresh = %v, want %v", fresh, tt.wantFresh)
+			}
+		})
+	}
+
+	//steps of other users are tracked separately
+	if fresh, err := UseTwoFactorStep(2, 100); err != nil || fresh {
+		t.Errorf("user without two factor: fresh = %v, %v", fresh, err)
+	}
+}
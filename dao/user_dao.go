@@ -2,6 +2,8 @@ package dao
 
 import (
 	"errors"
+	"strconv"
+	"task_manager/logger"
 	"task_manager/models"
 	"task_manager/utils"
 	"time"
@@ -10,7 +12,7 @@ import (
 )
 
 // Save user in DB
-func SaveUser(db *gorm.DB, u *models.User) (int64, string, string, error) {
+func SaveUser(db *gorm.DB, u *models.User, session models.SessionInfo) (int64, string, string, error) {
 	// Note the use of tx as the database handle once you are within a transaction
 	tx := db.Begin()
 	defer func() {
@@ -46,7 +48,7 @@ func SaveUser(db *gorm.DB, u *models.User) (int64, string, string, error) {
 	u.ID = user.ID
 
 	//Generate user token
-	userToken, refreshToken, err := utils.GenerateTokens(u.ID)
+	userToken, refreshToken, err := utils.GenerateTokens(u.ID, utils.SessionScopes(false))
 	if err != nil {
 		tx.Rollback()
 		return 0, "", "", err
@@ -65,11 +67,24 @@ func SaveUser(db *gorm.DB, u *models.User) (int64, string, string, error) {
 	}
 
 	//saving token in db
+	familyID, err := newFamilyID(session.FamilyID)
+	if err != nil {
+		tx.Rollback()
+		return 0, "", "", err
+	}
+
+	now := time.Now()
 	token := Token{
-		UserToken:    userToken,
-		RefreshToken: refreshToken,
-		Timestamp:    time.Now(),
-		UserID:       u.ID,
+		UserTokenHash:    utils.HashToken(userToken),
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		Timestamp:        now,
+		UserAgent:        session.UserAgent,
+		IP:               session.IP,
+		DeviceName:       session.DeviceName,
+		LastSeenAt:       &now,
+		FamilyID:         familyID,
+		ClientID:         session.ClientID,
+		UserID:           u.ID,
 	}
 
 	if err := tx.Create(&token).Error; err != nil {
@@ -84,13 +99,30 @@ func SaveUser(db *gorm.DB, u *models.User) (int64, string, string, error) {
 	return u.ID, userToken, refreshToken, nil
 }
 
-// Save pair of tokens in db
-func SaveToken(uid int64, user_token, refresh_token string) error {
+// Save pair of tokens in db along with the device metadata of the session
+func SaveToken(uid int64, user_token, refresh_token string, session models.SessionInfo) error {
+	//disabled accounts and accounts waiting for deletion cannot sign in again
+	if err := checkAccountActive(DB, uid); err != nil {
+		return err
+	}
+
+	familyID, err := newFamilyID(session.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
 	token := Token{
-		UserToken:    user_token,
-		RefreshToken: refresh_token,
-		Timestamp:    time.Now(),
-		UserID:       uid,
+		UserTokenHash:    utils.HashToken(user_token),
+		RefreshTokenHash: utils.HashToken(refresh_token),
+		Timestamp:        now,
+		UserAgent:        session.UserAgent,
+		IP:               session.IP,
+		DeviceName:       session.DeviceName,
+		LastSeenAt:       &now,
+		FamilyID:         familyID,
+		ClientID:         session.ClientID,
+		UserID:           uid,
 	}
 	if err := DB.Create(&token).Error; err != nil {
 		return err
@@ -99,8 +131,8 @@ func SaveToken(uid int64, user_token, refresh_token string) error {
 	return nil
 }
 
-// validate credentials
-func ValidateCredentials(u *models.Login) error {
+// validate credentials, requestID is used to log a failed rehash of the password
+func ValidateCredentials(requestID string, u *models.Login) error {
 	var login models.Login
 	if err := DB.Where("email = ?", u.Email).First(&login).Error; err != nil {
 		return errors.New("invalid credentials")
@@ -111,15 +143,33 @@ func ValidateCredentials(u *models.Login) error {
 		return errors.New("invalid credentials")
 	}
 
+	//upgrade hashes made with an older algorithm or parameters, the sign in succeeds either way
+	params := utils.GetPasswordHashParams()
+	if params.NeedsRehash(login.Password) {
+		if err := rehashPassword(&login, u.Password, params); err != nil {
+			logger.Error(requestID, "could not rehash password", err.Error(), "userID: "+strconv.Itoa(int(login.UserID)))
+		}
+	}
+
 	u.ID = login.ID
 	return nil
 }
 
+// Replace the password hash unless the password was changed in the meantime
+func rehashPassword(login *models.Login, password string, params utils.PasswordHashParams) error {
+	hashedPassword, err := params.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return DB.Model(&Login{}).Where("id = ? AND password = ?", login.ID, login.Password).Update("password", hashedPassword).Error
+}
+
 // Fetches user details from DB
 func GetUserById(uid int64) (*models.UserResponse, error) {
 
 	var user models.UserResponse
-	result := DB.Model(User{}).Select("name, mobile_no, gender, email ").Where("id =?", uid).First(&user)
+	result := DB.Model(User{}).Select("name, mobile_no, gender, email, email_verified").Where("id =?", uid).First(&user)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -130,7 +180,7 @@ func GetUserById(uid int64) (*models.UserResponse, error) {
 // delete refresh token from DB
 func DeleteRefreshToken(tokenString string) error {
 	var token Token
-	if err := DB.Where("refresh_token = ?", tokenString).First(&token).Error; err != nil {
+	if err := DB.Where("refresh_token_hash = ?", utils.HashToken(tokenString)).First(&token).Error; err != nil {
 		return err
 	}
 
@@ -166,20 +216,22 @@ func GetUserByIdPassChng(uid int64) (*Login, error) {
 	return &login, nil
 }
 
-// Update password in DB
-func UpdatePassById(uid int64, password string) error {
-	if err := DB.Model(&Login{}).Where("user_id = ?", uid).Update("password", password).Error; err != nil {
-		return err
-	}
+// Update password in DB, the replaced password is kept in the history
+func UpdatePassById(uid int64, password string, historySize int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := recordPasswordHistory(tx, uid, historySize); err != nil {
+			return err
+		}
 
-	return nil
+		return tx.Model(&Login{}).Where("user_id = ?", uid).Update("password", password).Error
+	})
 }
 
 // Delete all the tokens from DB except the token which user is login
 func DeleteTokenById(uid int64, tokenString string) error {
 	var token Token
 
-	result := DB.Where("user_id = ? AND user_token != ?", uid, tokenString).Delete(&token)
+	result := DB.Where("user_id = ? AND user_token_hash != ?", uid, utils.HashToken(tokenString)).Delete(&token)
 
 	if result.RowsAffected == 0 {
 		return nil
@@ -205,11 +257,28 @@ func SignOutAllUsers(uid int64) error {
 	return nil
 }
 
+// Signs out every session of user and revokes their personal access tokens,
+// for when the account may be compromised
+func SignOutEverywhere(uid int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return revokeAllAccess(tx, uid)
+	})
+}
+
+// delete every session and personal access token of user
+func revokeAllAccess(tx *gorm.DB, uid int64) error {
+	if err := tx.Where("user_id = ?", uid).Delete(&Token{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("user_id = ?", uid).Delete(&PersonalAccessToken{}).Error
+}
+
 // Signout single user
 func DeleteToken(tokenString string) error {
 	var token Token
 
-	if err := DB.Where("user_token = ? ", tokenString).First(&token).Error; err != nil {
+	if err := DB.Where("user_token_hash = ?", utils.HashToken(tokenString)).First(&token).Error; err != nil {
 		return err
 	}
 
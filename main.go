@@ -1,9 +1,11 @@
 package main
 
 import (
+	"task_manager/controller"
 	"task_manager/dao"
 	"task_manager/logger"
 	"task_manager/routes"
+	_ "time/tzdata"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -24,6 +26,14 @@ func main() {
 	dao.InitDB()
 	logger.Info("", "Database connection initialized")
 
+	controller.ScheduleSigningKeyRotation()
+	logger.Info("", "Signing keys loaded")
+
+	controller.ScheduleAccountPurge()
+	logger.Info("", "Account purge scheduled")
+
+	controller.BootstrapAdmin()
+
 	server := gin.Default()
 	logger.Info("", "Server initialized successfully")
 
@@ -17,7 +17,7 @@ import (
 func Authenticate(c *gin.Context) {
 	startTime := time.Now()
 	requestId := requestid.Get(c)
-	token := c.Request.Header.Get("Authorization")
+	token := RequestToken(c)
 	if token == "" {
 		logger.Warn("authorization-request-id", "Authorization token is missing", c.Request.Method, c.Request.URL.String(), requestId)
 		el := time.Since(startTime).Microseconds()
@@ -25,9 +25,12 @@ func Authenticate(c *gin.Context) {
 		return
 	}
 
-	token = strings.TrimPrefix(token, "Bearer ")
+	if utils.IsPersonalAccessToken(token) {
+		authenticatePersonalAccessToken(c, token, startTime)
+		return
+	}
 
-	userId, err := utils.VerifyJwtToken(token)
+	claims, err := utils.VerifyJwtToken(token)
 	if err != nil {
 		logger.Error("", "failed to verify user token", err.Error(), requestId)
 		ele := time.Since(startTime).Microseconds()
@@ -36,32 +39,114 @@ func Authenticate(c *gin.Context) {
 	}
 
 	c.Set("token", token)
-	c.Set("userId", userId)
+	c.Set("userId", claims.UserID)
+	c.Set("scopes", claims.Scopes)
+	if claims.ClientID != "" {
+		c.Set("clientId", claims.ClientID)
+	}
 
-	logger.Info("requestID", "user authenticated successfully", strconv.Itoa(int(userId)))
+	logger.Info("requestID", "user authenticated successfully", strconv.Itoa(int(claims.UserID)))
 	c.Next()
 }
 
+// Token of the request from the Authorization header, or the session cookie when there is no header
+func RequestToken(c *gin.Context) string {
+	if header := c.Request.Header.Get("Authorization"); header != "" {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if !utils.CookieSessionsEnabled() {
+		return ""
+	}
+
+	token, err := c.Cookie(utils.SessionCookie)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// Refresh token of the request from the Refresh-Token header, or the refresh cookie when there is no header
+func RequestRefreshToken(c *gin.Context) string {
+	if header := c.GetHeader("Refresh-Token"); header != "" {
+		return header
+	}
+	if !utils.CookieSessionsEnabled() {
+		return ""
+	}
+
+	token, err := c.Cookie(utils.RefreshCookie)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// Authenticates a request made with a personal access token
+func authenticatePersonalAccessToken(c *gin.Context, token string, startTime time.Time) {
+	requestId := requestid.Get(c)
+
+	pat, err := dao.GetPersonalAccessToken(utils.HashToken(token))
+	if err != nil {
+		logger.Error("", "failed to verify personal access token", err.Error(), requestId)
+		ele := time.Since(startTime).Microseconds()
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not Authorized", "error": true, "data": nil, "execution_time": ele, "request_id": requestId})
+		return
+	}
+
+	//record token usage at most once a minute
+	if err := dao.TouchPersonalAccessToken(pat.ID, time.Minute); err != nil {
+		logger.Error("requestID", "failed to update personal access token last used", err.Error())
+	}
+
+	c.Set("token", token)
+	c.Set("userId", pat.UserID)
+	c.Set("personalAccessTokenId", pat.ID)
+
+	//tokens created without scopes are granted every scope
+	scopes := utils.ParseScopes(pat.Scopes)
+	if len(scopes) == 0 {
+		scopes = utils.KnownScopes
+	}
+	c.Set("scopes", scopes)
+
+	logger.Info("requestID", "user authenticated with personal access token", strconv.Itoa(int(pat.UserID)), strconv.Itoa(int(pat.ID)))
+	c.Next()
+}
+
+// Checks whether the request was authenticated with a personal access token
+func IsPersonalAccessToken(c *gin.Context) bool {
+	_, exists := c.Get("personalAccessTokenId")
+	return exists
+}
+
 // checks whether user is signin or not
 func CheckTokenPresent(c *gin.Context) error {
-	token := c.Request.Header.Get("Authorization")
+	//personal access tokens are looked up in DB by Authenticate
+	if IsPersonalAccessToken(c) {
+		return nil
+	}
 
-	token = strings.TrimPrefix(token, "Bearer ")
+	token := RequestToken(c)
 
 	var dbToken dao.Token
 
-	err := dao.DB.Where("user_token = ? ", token).First(&dbToken).Error
+	err := dao.DB.Where("user_token_hash = ?", utils.HashToken(token)).First(&dbToken).Error
 	if err != nil {
 		return err
 	}
 
+	//record session activity at most once a minute
+	if err := dao.TouchSession(dbToken.ID, time.Minute); err != nil {
+		logger.Error("requestID", "failed to update session last seen", err.Error())
+	}
+
 	logger.Info("requestID", "token found in the database", strconv.Itoa(int(dbToken.ID)))
-	return err
+	return nil
 }
 
 // check whether refresh token is present in db or not
 func CheckRefreshToken(context *gin.Context) error {
-	refreshToken := context.Request.Header.Get("Refresh-Token")
+	refreshToken := RequestRefreshToken(context)
 	if refreshToken == "" {
 		logger.Error("requestID", "Refresh token missing", "error")
 		return nil
@@ -70,7 +155,7 @@ func CheckRefreshToken(context *gin.Context) error {
 
 	var dbToken dao.Token
 
-	err := dao.DB.Where("refresh_token = ?", refreshToken).First(&dbToken).Error
+	err := dao.DB.Where("refresh_token_hash = ?", utils.HashToken(refreshToken)).First(&dbToken).Error
 	if err != nil {
 		logger.Error("requestID", "session expired or refresh token not found", err.Error())
 		return err
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"task_manager/logger"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// Double submit CSRF protection for requests authenticated by session cookies,
+// the X-CSRF-Token header must repeat the value of the CSRF cookie
+func CSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		//tokens sent in headers cannot be attached by another site
+		if !usesSessionCookie(c) {
+			c.Next()
+			return
+		}
+
+		startTime := time.Now()
+		requestId := requestid.Get(c)
+
+		cookie, err := c.Cookie(utils.CSRFCookie)
+		header := c.GetHeader(utils.CSRFHeader)
+		if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			logger.Warn(requestId, "csrf token missing or does not match", c.Request.Method, c.Request.URL.Path)
+			el := time.Since(startTime).Microseconds()
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "invalid or missing csrf token", "error": true, "data": nil, "execution_time": el, "request_id": requestId})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// whether the request will be authenticated by a session or refresh cookie
+func usesSessionCookie(c *gin.Context) bool {
+	if !utils.CookieSessionsEnabled() || c.GetHeader("Authorization") != "" || c.GetHeader("Refresh-Token") != "" {
+		return false
+	}
+
+	if session, err := c.Cookie(utils.SessionCookie); err == nil && session != "" {
+		return true
+	}
+	if refresh, err := c.Cookie(utils.RefreshCookie); err == nil && refresh != "" {
+		return true
+	}
+
+	return false
+}
@@ -11,6 +11,12 @@ func ResponseFormatter() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 		c.Next()
+
+		//handlers that wrote their own body, like file downloads, are left as they are
+		if c.Writer.Written() {
+			return
+		}
+
 		elapsed := time.Since(startTime)
 		response, _ := c.Get("response")
 		message, _ := c.Get("message")
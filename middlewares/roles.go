@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// Rejects requests of users without the role, denied attempts are audited.
+// The role is read from the database so that demotions apply right away. Must run after Authenticate.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+		requestId := requestid.Get(c)
+		userId := c.GetInt64("userId")
+
+		userRole, err := dao.GetUserRole(userId)
+		if err != nil {
+			logger.Error(requestId, "failed to fetch user role", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+			el := time.Since(startTime).Microseconds()
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Not Authorized", "error": true, "data": nil, "execution_time": el, "request_id": requestId})
+			return
+		}
+
+		if userRole == role {
+			c.Set("role", userRole)
+			c.Next()
+			return
+		}
+
+		if err := dao.SaveAuditLog("role_access_denied", userId, c.ClientIP(), "required role: "+role+", "+c.Request.Method+" "+c.Request.URL.Path); err != nil {
+			logger.Error(requestId, "could not save audit log", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		}
+
+		logger.Warn(requestId, "role required", "role: "+role, "userID: "+strconv.Itoa(int(userId)), c.Request.Method, c.Request.URL.String())
+		el := time.Since(startTime).Microseconds()
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "this action requires the " + role + " role", "error": true, "data": nil, "execution_time": el, "request_id": requestId})
+	}
+}
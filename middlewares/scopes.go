@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"task_manager/logger"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// Rejects requests whose token was not granted every required scope,
+// must run after Authenticate
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+		requestId := requestid.Get(c)
+		userId := c.GetInt64("userId")
+
+		missing := utils.MissingScopes(c.GetStringSlice("scopes"), scopes)
+		if len(missing) == 0 {
+			c.Next()
+			return
+		}
+
+		message := "missing scope " + strings.Join(missing, ", ")
+		logger.Warn(requestId, "insufficient scope", message, "userID: "+strconv.Itoa(int(userId)), c.Request.Method, c.Request.URL.String())
+
+		//error description as defined for bearer tokens in RFC 6750
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+		el := time.Since(startTime).Microseconds()
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": message, "error": true, "data": gin.H{"missing_scopes": missing}, "execution_time": el, "request_id": requestId})
+	}
+}
+
+// Rejects requests made with a personal access token or a token issued to an OAuth client,
+// for actions that grant or manage access. Must run after Authenticate.
+func RequireFirstParty(c *gin.Context) {
+	startTime := time.Now()
+	requestId := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	_, thirdParty := c.Get("clientId")
+	if !thirdParty && !IsPersonalAccessToken(c) {
+		c.Next()
+		return
+	}
+
+	logger.Warn(requestId, "first party session required", "userID: "+strconv.Itoa(int(userId)), c.Request.Method, c.Request.URL.String())
+	el := time.Since(startTime).Microseconds()
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "this action requires a signed in session", "error": true, "data": nil, "execution_time": el, "request_id": requestId})
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"task_manager/dao"
+	"task_manager/logger"
+	"task_manager/utils"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// Restricts unverified accounts according to EMAIL_VERIFICATION_POLICY:
+// "none" never restricts, "strict" always restricts and "grace" (default)
+// restricts once UNVERIFIED_GRACE_PERIOD has passed since signup
+func RequireVerifiedEmail(c *gin.Context) {
+	startTime := time.Now()
+	requestId := requestid.Get(c)
+	userId := c.GetInt64("userId")
+
+	policy := os.Getenv("EMAIL_VERIFICATION_POLICY")
+	if policy == "none" {
+		c.Next()
+		return
+	}
+
+	user, err := dao.GetVerificationStatus(userId)
+	if err != nil {
+		logger.Error(requestId, "failed to fetch verification status", err.Error(), "userID: "+strconv.Itoa(int(userId)))
+		el := time.Since(startTime).Microseconds()
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not Authorized", "error": true, "data": nil, "execution_time": el, "request_id": requestId})
+		return
+	}
+
+	grace := utils.DurationFromEnv("UNVERIFIED_GRACE_PERIOD", 72*time.Hour)
+	if user.EmailVerified || (policy != "strict" && time.Since(user.CreatedAt) < grace) {
+		c.Next()
+		return
+	}
+
+	logger.Warn(requestId, "email verification required", "", "userID: "+strconv.Itoa(int(userId)))
+	el := time.Since(startTime).Microseconds()
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "email verification required", "error": true, "data": nil, "execution_time": el, "request_id": requestId})
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Request struct to delete the account, the password is confirmed again
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// Profile of the user in the data export
+type ExportProfile struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	MobileNo      string    `json:"mobile_no"`
+	Gender        string    `json:"gender"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+}
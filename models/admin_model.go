@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// User as listed on the admin routes
+type AdminUserResponse struct {
+	ID                  int64      `json:"id"`
+	Name                string     `json:"name"`
+	Email               string     `json:"email"`
+	MobileNo            string     `json:"mobile_no"`
+	Role                string     `json:"role"`
+	EmailVerified       bool       `json:"email_verified"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// Counts shown on the admin overview
+type SystemStats struct {
+	Users                int64 `json:"users"`
+	VerifiedUsers        int64 `json:"verified_users"`
+	DisabledUsers        int64 `json:"disabled_users"`
+	PendingDeletion      int64 `json:"pending_deletion"`
+	Admins               int64 `json:"admins"`
+	SignUpsLastWeek      int64 `json:"sign_ups_last_week"`
+	Sessions             int64 `json:"sessions"`
+	PersonalAccessTokens int64 `json:"personal_access_tokens"`
+	OAuthClients         int64 `json:"oauth_clients"`
+	Tasks                int64 `json:"tasks"`
+	CompletedTasks       int64 `json:"completed_tasks"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Supported custom field types
+const (
+	FieldTypeText         = "text"
+	FieldTypeNumber       = "number"
+	FieldTypeDate         = "date"
+	FieldTypeSingleSelect = "single_select"
+	FieldTypeMultiSelect  = "multi_select"
+	FieldTypeCheckbox     = "checkbox"
+	FieldTypeURL          = "url"
+)
+
+// Custom field definition owned by a user
+type CustomField struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name" binding:"required"`
+	Type    string   `json:"type" binding:"required"`
+	Options []string `json:"options"`
+}
+
+// Validated custom field value in its stored form
+type CustomFieldValue struct {
+	Value       string
+	NumberValue *float64
+	DateValue   *time.Time
+}
+
+// Filter on a custom field value used while fetching tasks
+type CustomFieldFilter struct {
+	Field CustomField
+	Value CustomFieldValue
+}
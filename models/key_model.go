@@ -0,0 +1,19 @@
+package models
+
+// Public signing key in JWK format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Response struct of the JWKS endpoint
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
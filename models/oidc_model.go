@@ -0,0 +1,9 @@
+package models
+
+// Identity claims of a user signed in through an external provider
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// User preferences for dates, sorting and paging
+type UserPreferences struct {
+	Timezone        string `json:"timezone"`
+	WeekStart       string `json:"week_start"`
+	DateFormat      string `json:"date_format"`
+	DefaultSort     string `json:"default_sort"`
+	DefaultPageSize int    `json:"default_page_size"`
+}
+
+// Due date range filter used while fetching tasks
+type DueRange struct {
+	From   *time.Time
+	Before *time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Request struct to create a task from free text
+type QuickAddRequest struct {
+	Text     string `json:"text" binding:"required"`
+	Timezone string `json:"timezone"`
+}
+
+// Structured interpretation of free text task
+type QuickAddResult struct {
+	Title      string     `json:"title"`
+	Priority   string     `json:"priority"`
+	Tags       []string   `json:"tags"`
+	DueDate    *time.Time `json:"due_date"`
+	Recurrence string     `json:"recurrence"`
+}
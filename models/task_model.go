@@ -4,11 +4,52 @@ import "time"
 
 // user task struct
 type Task struct {
-	ID          int64  `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   string `gorm:"default:false" json:"completed"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	UserID      int64 `json:"userId"`
+	ID              int64      `json:"id"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	Completed       string     `gorm:"default:false" json:"completed"`
+	Estimate        int        `gorm:"default:0" json:"estimate"`
+	EstimateUnit    string     `gorm:"default:points" json:"estimate_unit"`
+	RemainingEffort int        `gorm:"default:0" json:"remaining_effort"`
+	DueDate         *time.Time `json:"due_date"`
+	Priority        string     `json:"priority"`
+	Tags            string     `json:"tags"`
+	Recurrence      string     `json:"recurrence"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	UserID          int64                  `json:"userId"`
+	CustomFields    map[string]interface{} `gorm:"-" json:"custom_fields"`
+}
+
+// Request struct to update task effort and completion
+type UpdateTaskRequest struct {
+	// completes or reopens the task, left unchanged when omitted
+	Completed       *bool                  `json:"completed"`
+	Estimate        *int                   `json:"estimate"`
+	EstimateUnit    *string                `json:"estimate_unit"`
+	RemainingEffort *int                   `json:"remaining_effort"`
+	CustomFields    map[string]interface{} `json:"custom_fields"`
+}
+
+// Estimated and remaining effort totals for a group of tasks
+type EffortTotals struct {
+	Tasks            int `json:"tasks"`
+	EstimatedPoints  int `json:"estimated_points"`
+	RemainingPoints  int `json:"remaining_points"`
+	EstimatedMinutes int `json:"estimated_minutes"`
+	RemainingMinutes int `json:"remaining_minutes"`
+}
+
+// Effort totals for tasks created in the same week
+type WeeklyEffort struct {
+	Week      string    `json:"week"`
+	WeekStart time.Time `json:"week_start"`
+	EffortTotals
+}
+
+// Response struct for task effort summary
+type TaskSummary struct {
+	Total   EffortTotals            `json:"total"`
+	ByState map[string]EffortTotals `json:"by_state"`
+	ByWeek  []WeeklyEffort          `json:"by_week"`
 }
@@ -0,0 +1,22 @@
+package models
+
+// Reusable task template owned by a user
+type TaskTemplate struct {
+	ID    int64              `json:"id"`
+	Name  string             `json:"name" binding:"required"`
+	Items []TaskTemplateItem `json:"items" binding:"required"`
+}
+
+// Single task of a template, due date is relative to the anchor date
+type TaskTemplateItem struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Completed   string `json:"completed"`
+	DayOffset   int    `json:"day_offset"`
+}
+
+// Request struct to create tasks from a template
+type InstantiateTemplateRequest struct {
+	AnchorDate string            `json:"anchor_date" binding:"required"`
+	Values     map[string]string `json:"values"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // User Struct for request body
 type User struct {
 	ID        int64
@@ -15,15 +17,18 @@ type Login struct {
 	Email    string `json:"username"`
 	Password string `json:"password"`
 	UserID   int64
+	// read only sessions can only use read scopes
+	ReadOnly bool `json:"read_only" gorm:"-"`
 }
 
 // Response struct for Userdetails
 type UserResponse struct {
-	Name      string `json:"name"`
-	Mobile_No string `json:"mobile_no"`
-	Gender    string `json:"gender"`
-	Email     string `json:"email"`
-	Avatar    string `json:"avatar"`
+	Name          string `json:"name"`
+	Mobile_No     string `json:"mobile_no"`
+	Gender        string `json:"gender"`
+	Email         string `json:"email"`
+	Avatar        string `json:"avatar"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
 // Request struct to update user details
@@ -32,8 +37,75 @@ type UpdateUserRequest struct {
 	Mobile_No string `json:"mobile_no" binding:"required"`
 }
 
+// Request struct to change the email address
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
 // Request struct to update user password
 type UpdatePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
 	NewPassword string `json:"new_password" binding:"required"`
 }
+
+// Request struct to start password recovery
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// Request struct to email a sign in link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// Request struct to reset password with a recovery token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// Request struct carrying a two factor code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// Request struct to complete sign in with a second factor
+type TwoFactorSignInRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// Device metadata recorded with a session
+type SessionInfo struct {
+	UserAgent  string
+	IP         string
+	DeviceName string
+	// refresh token family the session belongs to, empty starts a new family
+	FamilyID string
+	// OAuth client the tokens are issued to, empty for first party sessions
+	ClientID string
+}
+
+// Response struct for a signed in session
+type SessionResponse struct {
+	ID         int64      `json:"id"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	ClientID   string     `json:"client_id,omitempty"`
+	Current    bool       `json:"current"`
+}
+
+// Validated claims of a user or refresh token
+type TokenClaims struct {
+	UserID int64
+	Scopes []string
+	// OAuth client the token was issued to, empty for first party tokens
+	ClientID  string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"task_manager/controller"
+	"task_manager/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes(server *gin.Engine) {
+	route := server.Group("/admin", middlewares.RequestID(), middlewares.CSRF())
+	read := middlewares.RequireScopes("user:read")
+	write := middlewares.RequireScopes("user:write")
+	admin := middlewares.RequireRole("admin")
+
+	route.GET("/users", middlewares.Authenticate, read, middlewares.RequireFirstParty, admin, controller.AdminListUsers, middlewares.ResponseFormatter())
+	route.GET("/users/:id", middlewares.Authenticate, read, middlewares.RequireFirstParty, admin, controller.AdminGetUser, middlewares.ResponseFormatter())
+	route.POST("/users/:id/disable", middlewares.Authenticate, write, middlewares.RequireFirstParty, admin, controller.AdminDisableUser, middlewares.ResponseFormatter())
+	route.POST("/users/:id/enable", middlewares.Authenticate, write, middlewares.RequireFirstParty, admin, controller.AdminEnableUser, middlewares.ResponseFormatter())
+	route.POST("/users/:id/signout", middlewares.Authenticate, write, middlewares.RequireFirstParty, admin, controller.AdminSignOutUser, middlewares.ResponseFormatter())
+	route.POST("/users/:id/password/reset", middlewares.Authenticate, write, middlewares.RequireFirstParty, admin, controller.AdminResetPassword, middlewares.ResponseFormatter())
+	route.GET("/stats", middlewares.Authenticate, read, middlewares.RequireFirstParty, admin, controller.AdminGetStats, middlewares.ResponseFormatter())
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"task_manager/controller"
+	"task_manager/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CustomFieldRoutes(server *gin.Engine) {
+	route := server.Group("/", middlewares.RequestID(), middlewares.CSRF())
+	read := middlewares.RequireScopes("tasks:read")
+	write := middlewares.RequireScopes("tasks:write")
+
+	route.POST("/fields", middlewares.Authenticate, write, controller.CreateCustomField, middlewares.ResponseFormatter())
+	route.GET("/fields", middlewares.Authenticate, read, controller.GetCustomFields, middlewares.ResponseFormatter())
+	route.DELETE("/fields/:id", middlewares.Authenticate, write, controller.DeleteCustomField, middlewares.ResponseFormatter())
+}
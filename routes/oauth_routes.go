@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"task_manager/controller"
+	"task_manager/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func OAuthRoutes(server *gin.Engine) {
+	route := server.Group("/oauth", middlewares.RequestID(), middlewares.CSRF())
+	read := middlewares.RequireScopes("user:read")
+	write := middlewares.RequireScopes("user:write")
+
+	route.POST("/clients", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.RegisterClient, middlewares.ResponseFormatter())
+	route.GET("/clients", middlewares.Authenticate, read, middlewares.RequireFirstParty, controller.GetClients, middlewares.ResponseFormatter())
+	route.DELETE("/clients/:id", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.DeleteClient, middlewares.ResponseFormatter())
+	route.GET("/authorize", middlewares.Authenticate, read, middlewares.RequireFirstParty, controller.GetAuthorization, middlewares.ResponseFormatter())
+	route.POST("/authorize", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.Authorize, middlewares.ResponseFormatter())
+	route.GET("/consents", middlewares.Authenticate, read, middlewares.RequireFirstParty, controller.GetConsents, middlewares.ResponseFormatter())
+	route.DELETE("/consents/:client_id", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.RevokeConsent, middlewares.ResponseFormatter())
+
+	//client facing endpoints answer in the formats of the OAuth RFCs
+	route.POST("/token", controller.IssueToken)
+	route.POST("/introspect", controller.IntrospectToken)
+	route.POST("/revoke", controller.RevokeToken)
+}
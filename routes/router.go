@@ -5,4 +5,9 @@ import "github.com/gin-gonic/gin"
 func RegisterRoutes(server *gin.Engine) {
 	UserRoutes(server)
 	TaskRoutes(server)
+	CustomFieldRoutes(server)
+	TemplateRoutes(server)
+	OAuthRoutes(server)
+	WellKnownRoutes(server)
+	AdminRoutes(server)
 }
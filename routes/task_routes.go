@@ -8,11 +8,15 @@ import (
 )
 
 func TaskRoutes(server *gin.Engine) {
-	route := server.Group("/", middlewares.RequestID())
+	route := server.Group("/", middlewares.RequestID(), middlewares.CSRF())
+	read := middlewares.RequireScopes("tasks:read")
+	write := middlewares.RequireScopes("tasks:write")
 
-	route.POST("/tasks", middlewares.Authenticate, controller.CreateTask, middlewares.ResponseFormatter())
-	route.GET("/tasks/:id", middlewares.Authenticate, controller.GetTask, middlewares.ResponseFormatter())
-	route.GET("/tasks", middlewares.Authenticate, controller.GetTasksByQuery, middlewares.ResponseFormatter())
-	route.PUT("/tasks/:id", middlewares.Authenticate, controller.UpdateTask, middlewares.ResponseFormatter())
-	route.DELETE("/tasks/:id", middlewares.Authenticate, controller.DeleteTask, middlewares.ResponseFormatter())
+	route.POST("/tasks", middlewares.Authenticate, write, middlewares.RequireVerifiedEmail, controller.CreateTask, middlewares.ResponseFormatter())
+	route.POST("/tasks/quick", middlewares.Authenticate, write, middlewares.RequireVerifiedEmail, controller.QuickAddTask, middlewares.ResponseFormatter())
+	route.GET("/tasks/summary", middlewares.Authenticate, read, controller.GetTaskSummary, middlewares.ResponseFormatter())
+	route.GET("/tasks/:id", middlewares.Authenticate, read, controller.GetTask, middlewares.ResponseFormatter())
+	route.GET("/tasks", middlewares.Authenticate, read, controller.GetTasksByQuery, middlewares.ResponseFormatter())
+	route.PUT("/tasks/:id", middlewares.Authenticate, write, controller.UpdateTask, middlewares.ResponseFormatter())
+	route.DELETE("/tasks/:id", middlewares.Authenticate, write, controller.DeleteTask, middlewares.ResponseFormatter())
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"task_manager/controller"
+	"task_manager/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TemplateRoutes(server *gin.Engine) {
+	route := server.Group("/templates", middlewares.RequestID(), middlewares.CSRF())
+	read := middlewares.RequireScopes("tasks:read")
+	write := middlewares.RequireScopes("tasks:write")
+
+	route.POST("", middlewares.Authenticate, write, controller.CreateTemplate, middlewares.ResponseFormatter())
+	route.GET("", middlewares.Authenticate, read, controller.GetTemplates, middlewares.ResponseFormatter())
+	route.GET("/:id", middlewares.Authenticate, read, controller.GetTemplate, middlewares.ResponseFormatter())
+	route.DELETE("/:id", middlewares.Authenticate, write, controller.DeleteTemplate, middlewares.ResponseFormatter())
+	route.POST("/:id/instantiate", middlewares.Authenticate, write, middlewares.RequireVerifiedEmail, controller.InstantiateTemplate, middlewares.ResponseFormatter())
+}
@@ -8,18 +8,45 @@ import (
 )
 
 func UserRoutes(server *gin.Engine) {
-	route := server.Group("/user", middlewares.RequestID())
+	route := server.Group("/user", middlewares.RequestID(), middlewares.CSRF())
+	read := middlewares.RequireScopes("user:read")
+	write := middlewares.RequireScopes("user:write")
 
 	route.POST("/signup", controller.SignUp, middlewares.ResponseFormatter())
 	route.POST("/signin", controller.SignIn, middlewares.ResponseFormatter())
+	route.POST("/signin/2fa", controller.SignInTwoFactor, middlewares.ResponseFormatter())
+	route.POST("/signin/magic", controller.RequestMagicLink, middlewares.ResponseFormatter())
+	route.GET("/signin/magic/verify", controller.VerifyMagicLink, middlewares.ResponseFormatter())
+	route.GET("/oidc/:provider/login", controller.OIDCLogin, middlewares.ResponseFormatter())
+	route.GET("/oidc/:provider/callback", controller.OIDCCallback, middlewares.ResponseFormatter())
+	route.POST("/password/forgot", controller.ForgotPassword, middlewares.ResponseFormatter())
+	route.POST("/password/reset", controller.ResetPassword, middlewares.ResponseFormatter())
+	route.GET("/verify", controller.VerifyEmail, middlewares.ResponseFormatter())
+	route.POST("/verify/resend", middlewares.Authenticate, write, controller.ResendVerification, middlewares.ResponseFormatter())
+	route.GET("/unlock", controller.UnlockAccount, middlewares.ResponseFormatter())
 
-	route.GET("", middlewares.Authenticate, controller.GetUser, middlewares.ResponseFormatter())
-	route.POST("/avatar", middlewares.Authenticate, controller.UploadAvatar, middlewares.ResponseFormatter())
+	route.GET("", middlewares.Authenticate, read, controller.GetUser, middlewares.ResponseFormatter())
+	route.DELETE("", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.DeleteAccount, middlewares.ResponseFormatter())
+	route.POST("/restore", controller.RestoreAccount, middlewares.ResponseFormatter())
+	route.GET("/export", middlewares.Authenticate, read, middlewares.RequireFirstParty, controller.ExportAccount, middlewares.ResponseFormatter())
+	route.POST("/avatar", middlewares.Authenticate, write, controller.UploadAvatar, middlewares.ResponseFormatter())
 	route.GET("/avatar/:id", controller.ReadAvatar, middlewares.ResponseFormatter())
-	route.DELETE("/avatar", middlewares.Authenticate, controller.DeleteAvatar, middlewares.ResponseFormatter())
+	route.DELETE("/avatar", middlewares.Authenticate, write, controller.DeleteAvatar, middlewares.ResponseFormatter())
 	route.POST("/refresh", controller.RefreshTokenHandler, middlewares.ResponseFormatter())
-	route.PUT("/updateuser", middlewares.Authenticate, controller.UpdateUser, middlewares.ResponseFormatter())
-	route.PUT("/updatepassword", middlewares.Authenticate, controller.UpdatePassword, middlewares.ResponseFormatter())
+	route.PUT("/updateuser", middlewares.Authenticate, write, controller.UpdateUser, middlewares.ResponseFormatter())
+	route.PUT("/updatepassword", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.UpdatePassword, middlewares.ResponseFormatter())
+	route.POST("/email", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.RequestEmailChange, middlewares.ResponseFormatter())
+	route.GET("/email/confirm", controller.ConfirmEmailChange, middlewares.ResponseFormatter())
 	route.DELETE("/signout", middlewares.Authenticate, controller.SignOut, middlewares.ResponseFormatter())
+	route.GET("/sessions", middlewares.Authenticate, read, controller.GetSessions, middlewares.ResponseFormatter())
+	route.DELETE("/sessions/:id", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.DeleteSession, middlewares.ResponseFormatter())
+	route.POST("/tokens", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.CreatePersonalAccessToken, middlewares.ResponseFormatter())
+	route.GET("/tokens", middlewares.Authenticate, read, middlewares.RequireFirstParty, controller.GetPersonalAccessTokens, middlewares.ResponseFormatter())
+	route.DELETE("/tokens/:id", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.DeletePersonalAccessToken, middlewares.ResponseFormatter())
+	route.GET("/preferences", middlewares.Authenticate, read, controller.GetPreferences, middlewares.ResponseFormatter())
+	route.PUT("/preferences", middlewares.Authenticate, write, controller.UpdatePreferences, middlewares.ResponseFormatter())
+	route.POST("/2fa/enroll", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.EnrollTwoFactor, middlewares.ResponseFormatter())
+	route.POST("/2fa/confirm", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.ConfirmTwoFactor, middlewares.ResponseFormatter())
+	route.DELETE("/2fa", middlewares.Authenticate, write, middlewares.RequireFirstParty, controller.DisableTwoFactor, middlewares.ResponseFormatter())
 
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"task_manager/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WellKnownRoutes(server *gin.Engine) {
+	route := server.Group("/.well-known")
+
+	route.GET("/jwks.json", controller.GetJWKS)
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "strings"
+
+// Derive a readable device name like "Chrome on Windows" from a user agent
+func DeviceNameFromUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"curl/", "curl"},
+		{"postman", "Postman"},
+		{"okhttp", "OkHttp"},
+		{"go-http-client", "Go client"},
+		{"python-requests", "Python client"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	for _, os := range []struct{ token, name string }{
+		{"iphone", "iPhone"},
+		{"ipad", "iPad"},
+		{"android", "Android"},
+		{"windows", "Windows"},
+		{"mac os x", "macOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, os.token) {
+			return browser + " on " + os.name
+		}
+	}
+
+	return browser
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+)
+
+// AES-256-GCM key derived from the env variable
+func encryptionKey(env string) ([]byte, error) {
+	secret := os.Getenv(env)
+	if secret == "" {
+		return nil, errors.New(env + " is not set")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	return key[:], nil
+}
+
+// Encrypt a secret to store it in DB, with the key of TOTP_ENC_KEY
+func EncryptSecret(plaintext string) (string, error) {
+	return encrypt("TOTP_ENC_KEY", plaintext)
+}
+
+// Decrypt a secret stored in DB
+func DecryptSecret(ciphertext string) (string, error) {
+	return decrypt("TOTP_ENC_KEY", ciphertext)
+}
+
+// Encrypt a private signing key to store it in DB, with the key of JWT_KEY_ENC_KEY
+func EncryptSigningKey(plaintext string) (string, error) {
+	return encrypt("JWT_KEY_ENC_KEY", plaintext)
+}
+
+// Decrypt a private signing key stored in DB
+func DecryptSigningKey(ciphertext string) (string, error) {
+	return decrypt("JWT_KEY_ENC_KEY", ciphertext)
+}
+
+func encrypt(env, plaintext string) (string, error) {
+	key, err := encryptionKey(env)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decrypt(env, ciphertext string) (string, error) {
+	key, err := encryptionKey(env)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted secret")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
@@ -1,17 +1,157 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
 
-//Hash the password
+
+//Hash the password with the configured algorithm and parameters
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 7)
-	return string(bytes), err
+	return GetPasswordHashParams().Hash(password)
 }
 
 
 //checks the plain text password with hash password
 func CheckPasswordHash(password, hashedPassword string) bool {
+	if strings.HasPrefix(hashedPassword, "$"+PasswordHashArgon2id+"$") {
+		return checkArgon2idHash(password, hashedPassword)
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
+
+// Supported password hash algorithms
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+// lengths of the argon2id salt and key in bytes
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Password hashing settings read from the environment
+type PasswordHashParams struct {
+	Algorithm string
+	// argon2id memory in KiB, passes over the memory and threads
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	BcryptCost  int
+}
+
+// Fetch password hashing settings from the environment or use defaults
+func GetPasswordHashParams() PasswordHashParams {
+	algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM")
+	if algorithm == "" {
+		algorithm = PasswordHashArgon2id
+	}
+
+	// clamp before converting, out of range values would wrap and argon2 panics on zero passes or threads
+	parallelism := min(intFromEnv("ARGON2_PARALLELISM", 1), math.MaxUint8)
+	return PasswordHashParams{
+		Algorithm:   algorithm,
+		Memory:      uint32(min(max(intFromEnv("ARGON2_MEMORY", 19456), 8*parallelism), math.MaxUint32)),
+		Iterations:  uint32(min(intFromEnv("ARGON2_ITERATIONS", 2), math.MaxUint32)),
+		Parallelism: uint8(parallelism),
+		BcryptCost:  min(max(intFromEnv("BCRYPT_COST", 12), bcrypt.MinCost), bcrypt.MaxCost),
+	}
+}
+
+// Hash a password, the result records the algorithm and parameters used
+func (p PasswordHashParams) Hash(password string) (string, error) {
+	switch p.Algorithm {
+	case PasswordHashArgon2id:
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyLength)
+		return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", PasswordHashArgon2id, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	case PasswordHashBcrypt:
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		return string(bytes), err
+	}
+
+	return "", errors.New("unsupported password hash algorithm, use argon2id or bcrypt")
+}
+
+// Whether a hash was made with another algorithm or parameters than the configured ones
+func (p PasswordHashParams) NeedsRehash(hashedPassword string) bool {
+	switch p.Algorithm {
+	case PasswordHashArgon2id:
+		hash, err := parseArgon2idHash(hashedPassword)
+		return err != nil || hash.version != argon2.Version || hash.memory != p.Memory ||
+			hash.iterations != p.Iterations || hash.parallelism != p.Parallelism || len(hash.key) != argon2KeyLength
+	case PasswordHashBcrypt:
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		return err != nil || cost != p.BcryptCost
+	}
+
+	return false
+}
+
+// decoded argon2id hash in the PHC string format
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func parseArgon2idHash(hashedPassword string) (*argon2idHash, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != PasswordHashArgon2id {
+		return nil, errors.New("invalid argon2id hash")
+	}
+
+	var hash argon2idHash
+	version, err := strconv.Atoi(strings.TrimPrefix(parts[2], "v="))
+	if err != nil {
+		return nil, errors.New("invalid argon2id hash version")
+	}
+	hash.version = version
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.memory, &hash.iterations, &hash.parallelism)
+	if err != nil || hash.iterations == 0 || hash.parallelism == 0 {
+		return nil, errors.New("invalid argon2id hash parameters")
+	}
+
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errors.New("invalid argon2id hash salt")
+	}
+	if hash.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(hash.key) == 0 {
+		return nil, errors.New("invalid argon2id hash key")
+	}
+
+	return &hash, nil
+}
+
+func checkArgon2idHash(password, hashedPassword string) bool {
+	hash, err := parseArgon2idHash(hashedPassword)
+	if err != nil || hash.version != argon2.Version {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), hash.salt, hash.iterations, hash.memory, hash.parallelism, uint32(len(hash.key)))
+	return subtle.ConstantTimeCompare(key, hash.key) == 1
+}
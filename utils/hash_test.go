@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestGetPasswordHashParamsClampsArgon2(t *testing.T) {
+	tests := []struct {
+		name                            string
+		memory, iterations, parallelism string
+		want                            PasswordHashParams
+	}{
+		{
+			name: "defaults",
+			want: PasswordHashParams{Memory: 19456, Iterations: 2, Parallelism: 1},
+		},
+		{
+			name:   "zero and negative values use defaults",
+			memory: "0", iterations: "-1", parallelism: "0",
+			want: PasswordHashParams{Memory: 19456, Iterations: 2, Parallelism: 1},
+		},
+		{
+			name:   "values that would wrap are clamped",
+			memory: "4294967296", iterations: "4294967296", parallelism: "256",
+			want: PasswordHashParams{Memory: 4294967295, Iterations: 4294967295, Parallelism: 255},
+		},
+		{
+			name:   "memory is at least 8 KiB per thread",
+			memory: "8", parallelism: "4",
+			want: PasswordHashParams{Memory: 32, Iterations: 2, Parallelism: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ARGON2_MEMORY", tt.memory)
+			t.Setenv("ARGON2_ITERATIONS", tt.iterations)
+			t.Setenv("ARGON2_PARALLELISM", tt.parallelism)
+
+			got := GetPasswordHashParams()
+			if got.Memory != tt.want.Memory || got.Iterations != tt.want.Iterations || got.Parallelism != tt.want.Parallelism {
+				t.Errorf("m=%d,t=%d,p=%d, want m=%d,t=%d,p=%d", got.Memory, got.Iterations, got.Parallelism,
+					tt.want.Memory, tt.want.Iterations, tt.want.Parallelism)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordHashRejectsZeroArgon2Parameters(t *testing.T) {
+	// would make argon2 panic if it reached IDKey
+	hashes := []string{
+		"$argon2id$v=19$m=19456,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=2,p=0$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+	}
+	for _, hash := range hashes {
+		if CheckPasswordHash("password", hash) {
+			t.Errorf("%s accepted", hash)
+		}
+	}
+
+	params := PasswordHashParams{Algorithm: PasswordHashArgon2id, Memory: 64, Iterations: 1, Parallelism: 1}
+	hash, err := params.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPasswordHash("password", hash) || CheckPasswordHash("other", hash) {
+		t.Error("hash does not verify its own password only")
+	}
+}
@@ -3,13 +3,21 @@ package utils
 import (
 	"errors"
 	"os"
+	"strconv"
+	"strings"
+	"task_manager/models"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Generate pair of tokens
-func GenerateTokens(userId int64) (string, string, error) {
+// Generate pair of tokens carrying the granted scopes
+func GenerateTokens(userId int64, scopes []string) (string, string, error) {
+	return GenerateClientTokens(userId, "", scopes)
+}
+
+// Generate pair of tokens issued to an OAuth client, an empty clientId issues first party tokens
+func GenerateClientTokens(userId int64, clientId string, scopes []string) (string, string, error) {
 	// Default expiration durations
 	const defaultUserExpDuration = "2h"
 	const defaultRefreshExpDuration = "4h"
@@ -31,14 +39,10 @@ func GenerateTokens(userId int64) (string, string, error) {
 	refreshDuration, _ := time.ParseDuration(refreshExpDuration)
 
 	// Calculate exp times for user token and refresh
-	userExpTime := time.Now().Add(userDuration).Unix()
-	refreshExpTime := time.Now().Add(refreshDuration).Unix()
+	now := time.Now()
+	userExpTime := now.Add(userDuration)
+	refreshExpTime := now.Add(refreshDuration)
 
-	// Fetch secrets from environment
-	userSecret := os.Getenv("JWT_SEC")
-	if userSecret == "" {
-		return "", "", errors.New("JWT_SEC is not set")
-	}
 	// Fetch secrets from environment
 	refreshSecret := os.Getenv("JWT_REF_SEC")
 	if refreshSecret == "" {
@@ -46,59 +50,197 @@ func GenerateTokens(userId int64) (string, string, error) {
 	}
 
 	// Generate user token
-	userToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userId": userId,
-		"exp":    userExpTime,
-	})
-	signedUserToken, _ := userToken.SignedString([]byte(userSecret))
+	userClaims, err := tokenClaims(userId, clientId, TokenTypeAccess, scopes, now, userExpTime)
+	if err != nil {
+		return "", "", err
+	}
+
+	signedUserToken, err := signUserToken(userClaims)
+	if err != nil {
+		return "", "", err
+	}
 
 	// Generate refresh token
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userId": userId,
-		"exp":    refreshExpTime,
-	})
+	refreshClaims, err := tokenClaims(userId, clientId, TokenTypeRefresh, scopes, now, refreshExpTime)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
 	signedRefreshToken, _ := refreshToken.SignedString([]byte(refreshSecret))
 
 	return signedUserToken, signedRefreshToken, nil
 }
 
-// verify user token
-func VerifyJwtToken(token string) (int64, error) {
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+// Values of the typ claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
 
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return nil, errors.New("unexpected signing method")
-		}
+// Issuer of the tokens from the JWT_ISSUER env variable
+func tokenIssuer() string {
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		return "task_manager"
+	}
 
-		return []byte(os.Getenv("JWT_SEC")), nil
-	})
+	return issuer
+}
+
+// Audience of the tokens from the JWT_AUDIENCE env variable
+func tokenAudience() string {
+	audience := os.Getenv("JWT_AUDIENCE")
+	if audience == "" {
+		return "task_manager"
+	}
+
+	return audience
+}
+
+// Registered claims along with the userId, typ, scope and client of a token
+func tokenClaims(userId int64, clientId, typ string, scopes []string, issuedAt, expiresAt time.Time) (jwt.MapClaims, error) {
+	jti, err := GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{
+		"iss":    tokenIssuer(),
+		"aud":    tokenAudience(),
+		"sub":    strconv.FormatInt(userId, 10),
+		"iat":    issuedAt.Unix(),
+		"nbf":    issuedAt.Unix(),
+		"exp":    expiresAt.Unix(),
+		"jti":    jti,
+		"typ":    typ,
+		"scope":  strings.Join(scopes, " "),
+		"userId": userId,
+	}
+	if clientId != "" {
+		claims["client_id"] = clientId
+	}
+
+	return claims, nil
+}
+
+// Parse a token and validate its registered claims and typ
+func verifyToken(token, typ string, keyFunc jwt.Keyfunc) (*models.TokenClaims, error) {
+	parsedToken, err := jwt.Parse(token, keyFunc,
+		jwt.WithIssuer(tokenIssuer()),
+		jwt.WithAudience(tokenAudience()),
+		jwt.WithLeeway(DurationFromEnv("JWT_LEEWAY", 30*time.Second)),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
-		return 0, errors.New("could not parse the token")
+		return nil, errors.New("could not parse the token")
 	}
 
 	tokenIsValid := parsedToken.Valid
 	if !tokenIsValid {
-		return 0, errors.New("invalid Token")
+		return nil, errors.New("invalid Token")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return 0, errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
+	}
+
+	//a refresh token is never accepted as user token and the other way round
+	if claims["typ"] != typ {
+		return nil, errors.New("unexpected token type")
+	}
+
+	if jti, ok := claims["jti"].(string); !ok || jti == "" {
+		return nil, errors.New("invalid token claims")
 	}
 
 	userId, ok := claims["userId"].(float64)
 	if !ok {
-		return 0, errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject != strconv.FormatInt(int64(userId), 10) {
+		return nil, errors.New("invalid token claims")
+	}
+
+	scope, ok := claims["scope"].(string)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	clientId, _ := claims["client_id"].(string)
+	result := models.TokenClaims{
+		UserID:   int64(userId),
+		Scopes:   ParseScopes(scope),
+		ClientID: clientId,
+	}
+	if issuedAt, _ := claims.GetIssuedAt(); issuedAt != nil {
+		result.IssuedAt = issuedAt.Time
+	}
+	if expiresAt, _ := claims.GetExpirationTime(); expiresAt != nil {
+		result.ExpiresAt = expiresAt.Time
+	}
+
+	return &result, nil
+}
+
+// Sign a user token with the active key of the keyset, or with JWT_SEC when none is configured
+func signUserToken(claims jwt.MapClaims) (string, error) {
+	if key := activeSigningKey(); key != nil {
+		token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+		token.Header["kid"] = key.Kid
+		return token.SignedString(key.Private)
+	}
+
+	userSecret := os.Getenv("JWT_SEC")
+	if userSecret == "" {
+		return "", errors.New("JWT_SEC is not set")
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(userSecret))
+}
+
+// Resolve the verification key of a user token from its kid
+func userTokenKey(token *jwt.Token) (interface{}, error) {
+	if kid, ok := token.Header["kid"].(string); ok {
+		key := signingKeyByKid(kid)
+		if key == nil {
+			return nil, errors.New("unknown signing key")
+		}
+
+		if token.Method.Alg() != key.Algorithm {
+			return nil, errors.New("unexpected signing method")
+		}
+
+		return key.Private.Public(), nil
+	}
+
+	// tokens signed with JWT_SEC stay valid while the secret is set
+	_, ok := token.Method.(*jwt.SigningMethodHMAC)
+	if !ok {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	userSecret := os.Getenv("JWT_SEC")
+	if userSecret == "" {
+		return nil, errors.New("JWT_SEC is not set")
 	}
 
-	return int64(userId), nil
+	return []byte(userSecret), nil
+}
+
+// verify user token
+func VerifyJwtToken(token string) (*models.TokenClaims, error) {
+	return verifyToken(token, TokenTypeAccess, userTokenKey)
 }
 
 // verify refresh token
-func VerifyRefreshToken(token string) (int64, error) {
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+func VerifyRefreshToken(token string) (*models.TokenClaims, error) {
+	return verifyToken(token, TokenTypeRefresh, func(token *jwt.Token) (interface{}, error) {
 
 		_, ok := token.Method.(*jwt.SigningMethodHMAC)
 		if !ok {
@@ -107,25 +249,59 @@ func VerifyRefreshToken(token string) (int64, error) {
 
 		return []byte(os.Getenv("JWT_REF_SEC")), nil
 	})
+}
 
-	if err != nil {
-		return 0, errors.New("could not parse the token")
+// Generate short lived challenge token issued after password check when two factor is enabled,
+// it carries the scopes of the session requested at sign in
+func GenerateChallengeToken(userId int64, scopes []string) (string, error) {
+	secret := os.Getenv("JWT_SEC")
+	if secret == "" {
+		return "", errors.New("JWT_SEC is not set")
 	}
 
-	tokenIsValid := parsedToken.Valid
-	if !tokenIsValid {
-		return 0, errors.New("invalid Token")
+	ttl := DurationFromEnv("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute)
+
+	// challenge tokens carry no userId claim so they are never accepted as user tokens
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"challengeUserId": userId,
+		"typ":             "2fa_challenge",
+		"scope":           strings.Join(scopes, " "),
+		"exp":             time.Now().Add(ttl).Unix(),
+	})
+
+	return token.SignedString([]byte(secret))
+}
+
+// verify challenge token
+func VerifyChallengeToken(token string) (int64, []string, error) {
+	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+
+		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+		if !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+
+		return []byte(os.Getenv("JWT_SEC")), nil
+	})
+
+	if err != nil || !parsedToken.Valid {
+		return 0, nil, errors.New("invalid or expired challenge token")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != "2fa_challenge" {
+		return 0, nil, errors.New("invalid challenge token claims")
+	}
+
+	userId, ok := claims["challengeUserId"].(float64)
 	if !ok {
-		return 0, errors.New("invalid token claims")
+		return 0, nil, errors.New("invalid challenge token claims")
 	}
 
-	userId, ok := claims["userId"].(float64)
+	scope, ok := claims["scope"].(string)
 	if !ok {
-		return 0, errors.New("invalid token claims")
+		return 0, nil, errors.New("invalid challenge token claims")
 	}
 
-	return int64(userId), nil
+	return int64(userId), ParseScopes(scope), nil
 }
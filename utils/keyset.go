@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"task_manager/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithm used when no asymmetric algorithm is configured, signs with JWT_SEC
+const AlgorithmHS256 = "HS256"
+
+// Private key of the access token keyset
+type SigningKey struct {
+	Kid       string
+	Algorithm string
+	Private   crypto.Signer
+	// published right away but only signs from this time on, zero for keys already active
+	ActivatesAt time.Time
+}
+
+var (
+	keySetMutex sync.RWMutex
+	// newest key first, verification accepts every key of the set
+	keySet []SigningKey
+)
+
+// Signing algorithm of access tokens from the JWT_ALG env variable
+func SigningAlgorithm() string {
+	alg := os.Getenv("JWT_ALG")
+	if alg == "" {
+		return AlgorithmHS256
+	}
+
+	return alg
+}
+
+// Validates a configured signing algorithm
+func ValidateSigningAlgorithm(alg string) error {
+	switch alg {
+	case AlgorithmHS256, "RS256", "ES256", "EdDSA":
+		return nil
+	}
+
+	return errors.New("unsupported signing algorithm, use HS256, RS256, ES256 or EdDSA")
+}
+
+// Generate a new private key, returns its kid and the PKCS #8 PEM encoding
+func GenerateSigningKey(alg string) (string, string, error) {
+	var private crypto.Signer
+	var err error
+
+	switch alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", "", errors.New("signing keys are not used with " + alg)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", "", err
+	}
+
+	kid, err := GenerateRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return kid, string(encoded), nil
+}
+
+// Parse a PEM encoded private key generated by GenerateSigningKey
+func ParseSigningKey(kid, alg, encoded string) (*SigningKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errors.New("invalid signing key encoding")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported signing key type")
+	}
+
+	matches := false
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		matches = alg == "RS256"
+	case *ecdsa.PrivateKey:
+		matches = alg == "ES256"
+	case ed25519.PrivateKey:
+		matches = alg == "EdDSA"
+	}
+	if !matches {
+		return nil, errors.New("signing key does not match algorithm " + alg)
+	}
+
+	return &SigningKey{Kid: kid, Algorithm: alg, Private: private}, nil
+}
+
+// Replace the keyset, keys are ordered newest first
+func SetSigningKeys(keys []SigningKey) {
+	keySetMutex.Lock()
+	defer keySetMutex.Unlock()
+
+	keySet = keys
+}
+
+// Key used to sign new access tokens, nil when tokens are signed with JWT_SEC.
+// This is the newest key whose activation time has passed.
+func activeSigningKey() *SigningKey {
+	keySetMutex.RLock()
+	defer keySetMutex.RUnlock()
+
+	now := time.Now()
+	for _, key := range keySet {
+		if key.ActivatesAt.After(now) {
+			continue
+		}
+		if key.Algorithm != SigningAlgorithm() {
+			return nil
+		}
+
+		active := key
+		return &active
+	}
+
+	return nil
+}
+
+// Find a verification key of the keyset by its kid
+func signingKeyByKid(kid string) *SigningKey {
+	keySetMutex.RLock()
+	defer keySetMutex.RUnlock()
+
+	for _, key := range keySet {
+		if key.Kid == kid {
+			found := key
+			return &found
+		}
+	}
+
+	return nil
+}
+
+func signingMethod(alg string) jwt.SigningMethod {
+	switch alg {
+	case "RS256":
+		return jwt.SigningMethodRS256
+	case "ES256":
+		return jwt.SigningMethodES256
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA
+	}
+
+	return jwt.SigningMethodHS256
+}
+
+// Public keys of the keyset so other services can verify access tokens
+func JWKS() models.JWKSet {
+	keySetMutex.RLock()
+	defer keySetMutex.RUnlock()
+
+	set := models.JWKSet{Keys: make([]models.JWK, 0, len(keySet))}
+	for _, key := range keySet {
+		jwk := models.JWK{Use: "sig", Kid: key.Kid, Alg: key.Algorithm}
+
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk.Kty = "EC"
+			jwk.Crv = "P-256"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, 32)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, 32)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Login protection settings read from the environment
+type LoginProtection struct {
+	// failures after which each attempt has to wait exponentially longer
+	BackoffAfter int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// failures after which the account or ip is locked
+	AccountThreshold int
+	IPThreshold      int
+	LockoutDuration  time.Duration
+	// failures older than window are forgotten
+	Window time.Duration
+}
+
+// Fetch login protection settings from the environment or use defaults
+func GetLoginProtection() LoginProtection {
+	return LoginProtection{
+		BackoffAfter:     intFromEnv("LOGIN_BACKOFF_AFTER", 3),
+		BaseDelay:        DurationFromEnv("LOGIN_BACKOFF_BASE", time.Second),
+		MaxDelay:         DurationFromEnv("LOGIN_BACKOFF_MAX", 5*time.Minute),
+		AccountThreshold: intFromEnv("LOGIN_LOCKOUT_THRESHOLD", 10),
+		IPThreshold:      intFromEnv("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+		LockoutDuration:  DurationFromEnv("LOGIN_LOCKOUT_DURATION", 30*time.Minute),
+		Window:           DurationFromEnv("LOGIN_ATTEMPT_WINDOW", time.Hour),
+	}
+}
+
+// Delay required after the last failure before the next attempt is allowed
+func (p LoginProtection) Backoff(failures int) time.Duration {
+	if failures < p.BackoffAfter {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.BackoffAfter; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+// Time to wait before the next attempt given the failures recorded for a key
+func (p LoginProtection) RetryAfter(failures int, lastFailureAt time.Time, lockedUntil *time.Time, now time.Time) time.Duration {
+	if lockedUntil != nil && now.Before(*lockedUntil) {
+		return lockedUntil.Sub(now)
+	}
+
+	if now.Sub(lastFailureAt) > p.Window {
+		return 0
+	}
+
+	next := lastFailureAt.Add(p.Backoff(failures))
+	if now.Before(next) {
+		return next.Sub(now)
+	}
+
+	return 0
+}
+
+// Attempt key of an account
+func AccountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// Attempt key of a client ip
+func IPAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Attempt key of sign in link requests from a client ip
+func MagicLinkAttemptKey(ip string) string {
+	return "magic_link_ip:" + ip
+}
+
+func intFromEnv(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"task_manager/logger"
+	"time"
+)
+
+// Mailer delivers plain text emails
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// LogMailer writes emails to the application log, meant for local use
+type LogMailer struct{}
+
+func (LogMailer) Send(to, subject, body string) error {
+	logger.Info("mailer", "email sent", "to: "+to, "subject: "+subject, body)
+	return nil
+}
+
+// FileMailer writes each email to a separate file in Dir, meant for local use
+type FileMailer struct {
+	Dir string
+}
+
+func (m FileMailer) Send(to, subject, body string) error {
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(to))
+	content := "To: " + to + "\r\nSubject: " + subject + "\r\n\r\n" + body + "\r\n"
+
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o600)
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (m SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host := strings.Split(m.Addr, ":")[0]
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	msg := "From: " + m.From + "\r\nTo: " + to + "\r\nSubject: " + subject + "\r\n\r\n" + body + "\r\n"
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, []byte(msg))
+}
+
+var (
+	mailer     Mailer
+	mailerOnce sync.Once
+)
+
+// GetMailer returns the mailer selected by the MAILER env variable (log, file or smtp)
+func GetMailer() Mailer {
+	mailerOnce.Do(func() {
+		switch os.Getenv("MAILER") {
+		case "file":
+			dir := os.Getenv("MAILER_FILE_DIR")
+			if dir == "" {
+				dir = "mails"
+			}
+			mailer = FileMailer{Dir: dir}
+		case "smtp":
+			mailer = SMTPMailer{
+				Addr:     os.Getenv("SMTP_ADDR"),
+				From:     os.Getenv("SMTP_FROM"),
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+			}
+		default:
+			mailer = LogMailer{}
+		}
+	})
+
+	return mailer
+}
+
+// SetMailer replaces the mailer, e.g. to capture emails
+func SetMailer(m Mailer) {
+	mailerOnce.Do(func() {})
+	mailer = m
+}
+
+// SendMailAsync sends an email in the background and logs failures, so response
+// times do not reveal whether an email was sent
+func SendMailAsync(requestID, to, subject, body string) {
+	m := GetMailer()
+	go func() {
+		if err := m.Send(to, subject, body); err != nil {
+			logger.Error(requestID, "failed to send email", err.Error(), "subject: "+subject)
+		}
+	}()
+}
+
+// AppURL returns the public base url used in links sent to users
+func AppURL() string {
+	url := os.Getenv("APP_URL")
+	if url == "" {
+		url = "http://localhost:8080"
+	}
+	return strings.TrimSuffix(url, "/")
+}
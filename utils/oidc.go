@@ -0,0 +1,420 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"task_manager/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// HTTP client used to reach identity providers, replaceable to point at a mock provider
+var OIDCHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// External OpenID Connect provider, configured through the OIDC_PROVIDERS list and
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and OIDC_<NAME>_SCOPES
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+}
+
+// provider metadata published at /.well-known/openid-configuration
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+}
+
+type cachedDiscovery struct {
+	doc       *oidcDiscovery
+	fetchedAt time.Time
+}
+
+type cachedKeys struct {
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+var (
+	oidcCacheMutex sync.Mutex
+	discoveryCache = map[string]cachedDiscovery{}
+	providerKeys   = map[string]cachedKeys{}
+)
+
+// Find a configured provider by name
+func GetOIDCProvider(name string) (*OIDCProvider, error) {
+	configured := false
+	for _, p := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		if strings.TrimSpace(p) == name && name != "" {
+			configured = true
+			break
+		}
+	}
+	if !configured {
+		return nil, errors.New("unknown identity provider")
+	}
+
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+	provider := OIDCProvider{
+		Name:         name,
+		Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+		ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+		ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		RedirectURL:  AppURL() + "/user/oidc/" + name + "/callback",
+	}
+	if provider.Issuer == "" || provider.ClientID == "" {
+		return nil, errors.New("identity provider " + name + " is not fully configured")
+	}
+	if len(provider.Scopes) == 0 {
+		provider.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &provider, nil
+}
+
+// Generate a PKCE code verifier and its S256 challenge (RFC 7636)
+func GeneratePKCE() (string, string, error) {
+	verifier, err := GenerateRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+// S256 code challenge of a PKCE code verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// URL of the provider to send the browser to
+func (p *OIDCProvider) AuthorizationURL(state, nonce, codeChallenge string) (string, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return doc.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange an authorization code and return the claims of the verified ID token
+func (p *OIDCProvider) Exchange(code, codeVerifier, nonce string) (*models.OIDCClaims, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	resp, err := OIDCHTTPClient.PostForm(doc.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, errors.New("token endpoint returned " + resp.Status + ": " + string(body))
+	}
+
+	var tokens oidcTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(doc, tokens.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	//some providers only release the email through the userinfo endpoint
+	if claims.Email == "" && doc.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		if err := p.fetchUserinfo(doc, tokens.AccessToken, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+// Verify signature, issuer, audience, expiry and nonce of an ID token
+func (p *OIDCProvider) verifyIDToken(doc *oidcDiscovery, idToken, nonce string) (*models.OIDCClaims, error) {
+	parsedToken, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return providerKey(doc.JwksURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithLeeway(DurationFromEnv("JWT_LEEWAY", 30*time.Second)),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, errors.New("invalid id token: " + err.Error())
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid id token claims")
+	}
+
+	if claims["nonce"] != nonce {
+		return nil, errors.New("id token nonce does not match")
+	}
+
+	//with several audiences the token must have been issued to us
+	if audience, _ := claims.GetAudience(); len(audience) > 1 && claims["azp"] != p.ClientID {
+		return nil, errors.New("id token was issued to another client")
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+
+	result := models.OIDCClaims{Subject: subject}
+	readProfileClaims(claims, &result)
+	return &result, nil
+}
+
+// Fill missing profile claims from the userinfo endpoint
+func (p *OIDCProvider) fetchUserinfo(doc *oidcDiscovery, accessToken string, claims *models.OIDCClaims) error {
+	req, err := http.NewRequest(http.MethodGet, doc.UserinfoEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := OIDCHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("userinfo endpoint returned " + resp.Status)
+	}
+
+	var info map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return err
+	}
+
+	//userinfo responses about another subject must be ignored
+	if info["sub"] != claims.Subject {
+		return errors.New("userinfo subject does not match id token")
+	}
+
+	readProfileClaims(info, claims)
+	return nil
+}
+
+func readProfileClaims(claims map[string]interface{}, result *models.OIDCClaims) {
+	if email, ok := claims["email"].(string); ok {
+		result.Email = strings.ToLower(strings.TrimSpace(email))
+	}
+	if name, ok := claims["name"].(string); ok {
+		result.Name = name
+	}
+
+	//a few providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+}
+
+// Fetch and cache the discovery document of the provider
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+	oidcCacheMutex.Lock()
+	cached, ok := discoveryCache[p.Issuer]
+	oidcCacheMutex.Unlock()
+	if ok && time.Since(cached.fetchedAt) < time.Hour {
+		return cached.doc, nil
+	}
+
+	var doc oidcDiscovery
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, err
+	}
+
+	//the issuer of the metadata must match the configured one exactly
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Issuer {
+		return nil, errors.New("discovery issuer does not match configured issuer")
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JwksURI == "" {
+		return nil, errors.New("incomplete provider metadata")
+	}
+
+	oidcCacheMutex.Lock()
+	discoveryCache[p.Issuer] = cachedDiscovery{doc: &doc, fetchedAt: time.Now()}
+	oidcCacheMutex.Unlock()
+
+	return &doc, nil
+}
+
+// Public key of the provider for a kid, the keyset is refetched once for unknown kids
+func providerKey(jwksURI, kid string) (crypto.PublicKey, error) {
+	oidcCacheMutex.Lock()
+	cached, ok := providerKeys[jwksURI]
+	oidcCacheMutex.Unlock()
+
+	if ok && time.Since(cached.fetchedAt) < time.Hour {
+		if key, found := cached.keys[kid]; found {
+			return key, nil
+		}
+		//providers rotate keys, but do not refetch more than once a minute
+		if time.Since(cached.fetchedAt) < time.Minute {
+			return nil, errors.New("unknown id token signing key")
+		}
+	}
+
+	var set models.JWKSet
+	if err := getJSON(jwksURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := publicKeyFromJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	oidcCacheMutex.Lock()
+	providerKeys[jwksURI] = cachedKeys{keys: keys, fetchedAt: time.Now()}
+	oidcCacheMutex.Unlock()
+
+	key, found := keys[kid]
+	if !found {
+		return nil, errors.New("unknown id token signing key")
+	}
+
+	return key, nil
+}
+
+// Decode an RSA, EC P-256 or Ed25519 public key in JWK format
+func publicKeyFromJWK(jwk models.JWK) (crypto.PublicKey, error) {
+	decode := func(s string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, errors.New("unsupported curve " + jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid EC public key")
+		}
+		return key, nil
+	case "OKP":
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("unsupported OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.New("unsupported key type " + jwk.Kty)
+}
+
+func getJSON(url string, v interface{}) error {
+	resp, err := OIDCHTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(url + " returned " + resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"task_manager/models"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mockClientID = "task_manager"
+	mockCode     = "mock-code"
+	mockKid      = "mock-key"
+)
+
+// local OpenID Connect provider issuing ID tokens for a single authorization code
+type mockOIDCProvider struct {
+	server *httptest.Server
+	// key published in the JWKS
+	key *rsa.PrivateKey
+	// key the ID token is signed with, the published key unless a test replaces it
+	signingKey *rsa.PrivateKey
+	// PKCE challenge the authorization code was issued for
+	challenge string
+	claims    jwt.MapClaims
+	// issuer announced in the discovery document
+	issuer string
+}
+
+func newMockOIDCProvider(t *testing.T, challenge, nonce string) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockOIDCProvider{key: key, signingKey: key, challenge: challenge}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/jwks", m.jwks)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	m.issuer = m.server.URL
+	now := time.Now()
+	m.claims = jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "subject-1",
+		"aud":            mockClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          "Jane@Example.com",
+		"email_verified": true,
+		"name":           "Jane",
+	}
+
+	return m
+}
+
+func (m *mockOIDCProvider) provider() *OIDCProvider {
+	return &OIDCProvider{
+		Name:        "mock",
+		Issuer:      m.server.URL,
+		ClientID:    mockClientID,
+		Scopes:      []string{"openid", "email"},
+		RedirectURL: "http://localhost/user/oidc/mock/callback",
+	}
+}
+
+func (m *mockOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 m.issuer,
+		"authorization_endpoint": m.server.URL + "/authorize",
+		"token_endpoint":         m.server.URL + "/token",
+		"jwks_uri":               m.server.URL + "/jwks",
+	})
+}
+
+func (m *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != mockCode ||
+		r.PostForm.Get("client_id") != mockClientID || PKCEChallenge(r.PostForm.Get("code_verifier")) != m.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims)
+	token.Header["kid"] = mockKid
+	idToken, err := token.SignedString(m.signingKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "mock-access-token", "id_token": idToken})
+}
+
+func (m *mockOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	public := m.key.PublicKey
+	json.NewEncoder(w).Encode(models.JWKSet{Keys: []models.JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Kid: mockKid,
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+	}}})
+}
+
+func TestOIDCAuthorizationURL(t *testing.T) {
+	verifier, challenge, err := GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PKCEChallenge(verifier) != challenge {
+		t.Fatal("challenge does not match verifier")
+	}
+
+	mock := newMockOIDCProvider(t, challenge, "nonce-1")
+	authorizationURL, err := mock.provider().AuthorizationURL("state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authorizationURL, mock.server.URL+"/authorize?") {
+		t.Errorf("authorization url %q does not use the discovered endpoint", authorizationURL)
+	}
+
+	query := parsed.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             mockClientID,
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        challenge,
+		"code_challenge_method": "S256",
+		"scope":                 "openid email",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// changes the provider before the exchange
+		setup func(m *mockOIDCProvider)
+		// verifier sent instead of the one the code was issued for
+		verifier string
+		nonce    string
+		wantErr  string
+	}{
+		{name: "valid"},
+		{name: "wrong code verifier", verifier: "another-verifier", wantErr: "invalid_grant"},
+		{name: "signed with unknown key", setup: func(m *mockOIDCProvider) { m.signingKey = otherKey }, wantErr: "invalid id token"},
+		{name: "nonce mismatch", nonce: "another-nonce", wantErr: "nonce does not match"},
+		{name: "issued to another client", setup: func(m *mockOIDCProvider) { m.claims["aud"] = "another-client" }, wantErr: "invalid id token"},
+		{
+			name: "several audiences without azp",
+			setup: func(m *mockOIDCProvider) {
+				m.claims["aud"] = []string{mockClientID, "another-client"}
+			},
+			wantErr: "issued to another client",
+		},
+		{
+			name: "several audiences with azp",
+			setup: func(m *mockOIDCProvider) {
+				m.claims["aud"] = []string{mockClientID, "another-client"}
+				m.claims["azp"] = mockClientID
+			},
+		},
+		{name: "expired", setup: func(m *mockOIDCProvider) { m.claims["exp"] = time.Now().Add(-time.Hour).Unix() }, wantErr: "invalid id token"},
+		{name: "wrong issuer", setup: func(m *mockOIDCProvider) { m.claims["iss"] = "https://attacker.example" }, wantErr: "invalid id token"},
+		{name: "discovery issuer mismatch", setup: func(m *mockOIDCProvider) { m.issuer = "https://attacker.example" }, wantErr: "discovery issuer does not match"},
+		{name: "no subject", setup: func(m *mockOIDCProvider) { delete(m.claims, "sub") }, wantErr: "no subject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, challenge, err := GeneratePKCE()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mock := newMockOIDCProvider(t, challenge, "nonce-1")
+			if tt.setup != nil {
+				tt.setup(mock)
+			}
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			nonce := "nonce-1"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			claims, err := mock.provider().Exchange(mockCode, verifier, nonce)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := models.OIDCClaims{Subject: "subject-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+			if *claims != want {
+				t.Errorf("claims = %+v, want %+v", *claims, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Password policy settings read from the environment
+type PasswordPolicy struct {
+	MinLength int
+	// bcrypt ignores everything after 72 bytes
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// reject passwords containing the email or name of the user
+	DisallowPersonalInfo bool
+	// number of previous passwords that cannot be reused besides the current one
+	HistorySize int
+	// directory of breached password hashes, one file per SHA-1 prefix
+	BreachedListDir string
+}
+
+// length of the SHA-1 prefix naming a file of the breached list
+const breachedPrefixLength = 5
+
+// Fetch the password policy from the environment or use defaults
+func GetPasswordPolicy() PasswordPolicy {
+	classes := os.Getenv("PASSWORD_CHARACTER_CLASSES")
+	if classes == "" {
+		classes = "upper,lower,digit,special"
+	}
+
+	policy := PasswordPolicy{
+		MinLength:            intFromEnv("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:            intFromEnv("PASSWORD_MAX_LENGTH", 72),
+		DisallowPersonalInfo: os.Getenv("PASSWORD_ALLOW_PERSONAL_INFO") != "true",
+		HistorySize:          intFromEnv("PASSWORD_HISTORY", 5),
+		BreachedListDir:      os.Getenv("PASSWORD_BREACHED_LIST_DIR"),
+	}
+	for _, class := range strings.Split(classes, ",") {
+		switch strings.TrimSpace(class) {
+		case "upper":
+			policy.RequireUpper = true
+		case "lower":
+			policy.RequireLower = true
+		case "digit":
+			policy.RequireDigit = true
+		case "special":
+			policy.RequireSpecial = true
+		}
+	}
+
+	return policy
+}
+
+// Check a password against the policy, personal holds the email and name of the user
+func (p PasswordPolicy) Check(password string, personal ...string) error {
+	if len(password) < p.MinLength {
+		return errors.New("must be at least " + strconv.Itoa(p.MinLength) + " characters long")
+	}
+	if len(password) > p.MaxLength {
+		return errors.New("must be at most " + strconv.Itoa(p.MaxLength) + " characters long")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		case unicode.IsPunct(char) || unicode.IsSymbol(char):
+			hasSpecial = true
+		}
+	}
+
+	var missing []string
+	if p.RequireUpper && !hasUpper {
+		missing = append(missing, "one uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		missing = append(missing, "one lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		missing = append(missing, "one digit")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		missing = append(missing, "one special character")
+	}
+	if len(missing) > 0 {
+		return errors.New("must contain at least " + strings.Join(missing, ", "))
+	}
+
+	if p.DisallowPersonalInfo {
+		lower := strings.ToLower(password)
+		for _, part := range personalInfoParts(personal) {
+			if strings.Contains(lower, part) {
+				return errors.New("must not contain your name or email")
+			}
+		}
+	}
+
+	if p.BreachedListDir != "" {
+		breached, err := IsBreachedPassword(p.BreachedListDir, password)
+		if err != nil {
+			return errors.New("could not be checked against known breaches")
+		}
+		if breached {
+			return errors.New("has appeared in a data breach, choose a different one")
+		}
+	}
+
+	return nil
+}
+
+// Max length check for passwords that are only compared, not set
+func (p PasswordPolicy) CheckLength(password string) error {
+	if password == "" {
+		return errors.New("is required")
+	}
+	if len(password) > p.MaxLength {
+		return errors.New("must be at most " + strconv.Itoa(p.MaxLength) + " characters long")
+	}
+
+	return nil
+}
+
+// Look up the password in a breached list laid out like the k-anonymity range API:
+// the file named by the first five hex digits of its SHA-1 lists "SUFFIX:COUNT" lines
+func IsBreachedPassword(dir, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+
+	file, err := os.Open(filepath.Join(dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		candidate, count, _ := strings.Cut(line, ":")
+		if !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		//padding entries of the range format have a count of zero
+		return strings.TrimSpace(count) != "0", nil
+	}
+
+	return false, scanner.Err()
+}
+
+// Check whether password matches one of the given password hashes
+func PasswordInHistory(password string, hashes []string) bool {
+	for _, hash := range hashes {
+		if CheckPasswordHash(password, hash) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lowercased email local part and name words long enough to be meaningful
+func personalInfoParts(personal []string) []string {
+	var parts []string
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, found := strings.Cut(value, "@"); found {
+			value = local
+		}
+		for _, word := range strings.FieldsFunc(value, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			if len(word) >= 3 {
+				parts = append(parts, word)
+			}
+		}
+	}
+
+	return parts
+}
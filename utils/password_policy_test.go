@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// prefix and suffix of the upper case SHA-1 of password as used by the breached list
+func breachedHash(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+}
+
+// write a breached list with the given counts per password into a temp dir
+func writeBreachedList(t *testing.T, counts map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string][]string{}
+	for password, count := range counts {
+		prefix, suffix := breachedHash(password)
+		files[prefix] = append(files[prefix], suffix+":"+count)
+	}
+	for prefix, lines := range files {
+		// suffixes in lower case and with spaces around the count still match
+		content := "0000000000000000000000000000000000A:12\r\n" + strings.ToLower(strings.Join(lines, " \n")) + "\n"
+		if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	dir := writeBreachedList(t, map[string]string{
+		"Password1!": "3861493",
+		"Padded#Pw9": "0",
+	})
+
+	// a prefix file that cannot be read
+	unreadable := t.TempDir()
+	prefix, _ := breachedHash("Unread#Pw9")
+	if err := os.Mkdir(filepath.Join(unreadable, prefix+".txt"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	strict := PasswordPolicy{
+		MinLength:            8,
+		MaxLength:            72,
+		RequireUpper:         true,
+		RequireLower:         true,
+		RequireDigit:         true,
+		RequireSpecial:       true,
+		DisallowPersonalInfo: true,
+		BreachedListDir:      dir,
+	}
+	lenient := PasswordPolicy{MinLength: 4, MaxLength: 10}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		personal []string
+		// part of the expected error, empty when the password is accepted
+		wantErr string
+	}{
+		{name: "strong password", policy: strict, password: "Tr0ub4dor&3", personal: []string{"jane.doe@example.com", "Jane Doe"}},
+		{name: "too short", policy: strict, password: "Ab1!", wantErr: "at least 8 characters"},
+		{name: "too long", policy: strict, password: "Ab1!" + strings.Repeat("a", 69), wantErr: "at most 72 characters"},
+		{name: "missing classes are listed", policy: strict, password: "abcdefgh", wantErr: "one uppercase letter, one digit, one special character"},
+		{name: "unicode letters count", policy: strict, password: "Ärger#2024x"},
+		{name: "symbol counts as special", policy: strict, password: "Plus+Sign1"},
+		{name: "classes not required", policy: lenient, password: "aaaa"},
+		{name: "lenient maximum", policy: lenient, password: "aaaaaaaaaaa", wantErr: "at most 10 characters"},
+		{name: "contains email local part", policy: strict, password: "My#JaneDoe1", personal: []string{"janedoe@example.com"}, wantErr: "name or email"},
+		{name: "contains a name word", policy: strict, password: "Doe#Secret1", personal: []string{"x@example.com", "Jane Doe"}, wantErr: "name or email"},
+		{name: "email domain is allowed", policy: strict, password: "Example#Pw1", personal: []string{"jd@example.com"}},
+		{name: "short name words are allowed", policy: strict, password: "Al#Secret12", personal: []string{"al@example.com", "Al Li"}},
+		{name: "personal info allowed", policy: PasswordPolicy{MinLength: 8, MaxLength: 72}, password: "janedoe123", personal: []string{"janedoe@example.com"}},
+		{name: "breached", policy: strict, password: "Password1!", wantErr: "data breach"},
+		{name: "zero count padding entry", policy: strict, password: "Padded#Pw9"},
+		{name: "missing prefix file", policy: strict, password: "Unlisted#Pw9"},
+		{name: "unreadable list", policy: PasswordPolicy{MinLength: 8, MaxLength: 72, BreachedListDir: unreadable}, password: "Unread#Pw9", wantErr: "could not be checked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password, tt.personal...)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Check() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsBreachedPassword(t *testing.T) {
+	dir := writeBreachedList(t, map[string]string{"hunter2": "17043", "padding": "0"})
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{name: "listed", password: "hunter2", want: true},
+		{name: "padding entry", password: "padding"},
+		{name: "no prefix file", password: "not listed anywhere"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsBreachedPassword(dir, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsBreachedPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	//a password sharing the prefix file but not the suffix is not breached
+	prefix, suffix := breachedHash("hunter2")
+	other := strings.Repeat("F", len(suffix))
+	if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(other+":5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if breached, err := IsBreachedPassword(dir, "hunter2"); err != nil || breached {
+		t.Errorf("IsBreachedPassword() = %v, %v, want false", breached, err)
+	}
+}
+
+func TestPersonalInfoParts(t *testing.T) {
+	tests := []struct {
+		name     string
+		personal []string
+		want     []string
+	}{
+		{name: "email local part only", personal: []string{"Jane.Doe@Example.com"}, want: []string{"jane", "doe"}},
+		{name: "name words", personal: []string{"  Mary-Ann O'Neil "}, want: []string{"mary", "ann", "neil"}},
+		{name: "short words dropped", personal: []string{"Al Li", "jo@x.io"}},
+		{name: "digits kept", personal: []string{"user123@example.com"}, want: []string{"user123"}},
+		{name: "empty", personal: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := personalInfoParts(tt.personal); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("personalInfoParts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPasswordPolicyClasses(t *testing.T) {
+	t.Setenv("PASSWORD_CHARACTER_CLASSES", "lower, digit,unknown")
+	t.Setenv("PASSWORD_ALLOW_PERSONAL_INFO", "true")
+
+	policy := GetPasswordPolicy()
+	if policy.RequireUpper || !policy.RequireLower || !policy.RequireDigit || policy.RequireSpecial {
+		t.Errorf("classes = upper %v, lower %v, digit %v, special %v, want lower and digit",
+			policy.RequireUpper, policy.RequireLower, policy.RequireDigit, policy.RequireSpecial)
+	}
+	if policy.DisallowPersonalInfo {
+		t.Error("personal info disallowed although allowed")
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"task_manager/models"
+	"time"
+)
+
+// Prefix telling personal access tokens apart from JWTs
+const PersonalAccessTokenPrefix = "tmpat_"
+
+// Generate a personal access token, returns the token and a hint to recognise it by
+func GeneratePersonalAccessToken() (string, string, error) {
+	random, err := GenerateRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	token := PersonalAccessTokenPrefix + random
+	return token, token[:len(PersonalAccessTokenPrefix)+4], nil
+}
+
+// Checks whether a bearer token is a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// Validates the request to create a personal access token
+func ValidatePersonalAccessToken(req *models.CreatePersonalAccessTokenRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("token name is required")
+	}
+	if len(req.Name) > 100 {
+		return errors.New("token name must be at most 100 characters")
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+
+	return ValidateScopes(req.Scopes)
+}
@@ -0,0 +1,430 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"task_manager/models"
+	"time"
+)
+
+var (
+	priorities = map[string]string{
+		"low":    "low",
+		"medium": "medium",
+		"med":    "medium",
+		"high":   "high",
+		"urgent": "urgent",
+		"1":      "urgent",
+		"2":      "high",
+		"3":      "medium",
+		"4":      "low",
+	}
+
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday, "sunday": time.Sunday,
+		"mon": time.Monday, "monday": time.Monday,
+		"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+		"wed": time.Wednesday, "wednesday": time.Wednesday,
+		"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+		"fri": time.Friday, "friday": time.Friday,
+		"sat": time.Saturday, "saturday": time.Saturday,
+	}
+
+	rruleDays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+	clockPattern   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	ordinalPattern = regexp.MustCompile(`^(\d{1,2})(st|nd|rd|th)$`)
+)
+
+// Priorities accepted on tasks
+func ValidPriority(priority string) bool {
+	for _, p := range priorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse free text like "Pay rent every 1st of month !high #finance due tomorrow 9am"
+// into a title and structured metadata. Relative dates are resolved against now,
+// in the location of now. Absolute dates may use ISO or the preferred date format,
+// and "next week" starts on the preferred week start. Dates without a time are due
+// at the end of the day.
+func ParseQuickAdd(text string, now time.Time, prefs models.UserPreferences) (models.QuickAddResult, error) {
+	result := models.QuickAddResult{Tags: []string{}}
+	words := strings.Fields(text)
+	var title []string
+
+	var recurrence *rrule
+	var recurrenceHour, recurrenceMinute = -1, 0
+
+	for i := 0; i < len(words); {
+		word := words[i]
+		lower := strings.ToLower(word)
+
+		switch {
+		case strings.HasPrefix(lower, "!") && priorities[lower[1:]] != "":
+			result.Priority = priorities[lower[1:]]
+			i++
+			continue
+
+		case strings.HasPrefix(lower, "#") && len(quickAddTags(lower[1:])) > 0:
+			for _, tag := range quickAddTags(lower[1:]) {
+				if !hasOption(result.Tags, tag) {
+					result.Tags = append(result.Tags, tag)
+				}
+			}
+			i++
+			continue
+
+		case lower == "due" || lower == "on":
+			date, consumed, ok := parseDate(words[i+1:], now, prefs)
+			hour, minute, timeConsumed, timeOk := parseClock(words[i+1+consumed:])
+			if !ok && !timeOk {
+				break
+			}
+			if !ok {
+				date = now
+			}
+			due := endOfDay(date)
+			if timeOk {
+				due = time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, now.Location())
+			}
+			result.DueDate = &due
+			i += 1 + consumed + timeConsumed
+			continue
+
+		case lower == "every":
+			rule, consumed, ok := parseRecurrence(words[i+1:])
+			if !ok {
+				break
+			}
+			recurrence = &rule
+			result.Recurrence = rule.String()
+			i += 1 + consumed
+
+			// a time directly after the recurrence sets the time of the first occurrence
+			if hour, minute, timeConsumed, ok := parseClock(words[i:]); ok {
+				recurrenceHour, recurrenceMinute = hour, minute
+				i += timeConsumed
+			}
+			continue
+		}
+
+		title = append(title, word)
+		i++
+	}
+
+	result.Title = strings.TrimSpace(strings.Join(title, " "))
+	if result.Title == "" {
+		return result, errors.New("task text must contain a title")
+	}
+
+	// recurring tasks without an explicit due date are due on their first occurrence
+	if result.DueDate == nil && recurrence != nil {
+		due := recurrence.dueOn(recurrence.firstOccurrence(now), recurrenceHour, recurrenceMinute)
+		if due.Before(now) {
+			due = recurrence.dueOn(recurrence.firstOccurrence(now.AddDate(0, 0, 1)), recurrenceHour, recurrenceMinute)
+		}
+		result.DueDate = &due
+	}
+
+	return result, nil
+}
+
+// tags of a word after its "#", tags are stored comma separated so "#a,b" gives "a" and "b"
+func quickAddTags(word string) []string {
+	var tags []string
+	for _, part := range strings.Split(word, ",") {
+		tag := strings.Trim(part, ".,;:#")
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// parse a relative or absolute date, returns the number of words consumed
+func parseDate(words []string, now time.Time, prefs models.UserPreferences) (time.Time, int, bool) {
+	if len(words) == 0 {
+		return time.Time{}, 0, false
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	first := strings.ToLower(strings.Trim(words[0], ".,;"))
+
+	switch first {
+	case "today", "tonight":
+		return today, 1, true
+	case "tomorrow", "tmr", "tmrw":
+		return today.AddDate(0, 0, 1), 1, true
+	}
+
+	if day, ok := weekdays[first]; ok {
+		return nextWeekday(today, day), 1, true
+	}
+
+	if first == "next" && len(words) > 1 {
+		second := strings.ToLower(strings.Trim(words[1], ".,;"))
+		if day, ok := weekdays[second]; ok {
+			return nextWeekday(today, day), 2, true
+		}
+		switch second {
+		case "week":
+			return nextWeekday(today, PreferredWeekStart(prefs)), 2, true
+		case "month":
+			return today.AddDate(0, 1, 0), 2, true
+		case "year":
+			return today.AddDate(1, 0, 0), 2, true
+		}
+	}
+
+	if first == "in" && len(words) > 2 {
+		count, err := strconv.Atoi(words[1])
+		if err == nil && count > 0 && count <= 1000 {
+			switch strings.TrimSuffix(strings.ToLower(strings.Trim(words[2], ".,;")), "s") {
+			case "day":
+				return today.AddDate(0, 0, count), 3, true
+			case "week":
+				return today.AddDate(0, 0, 7*count), 3, true
+			case "month":
+				return today.AddDate(0, count, 0), 3, true
+			}
+		}
+	}
+
+	for _, layout := range []string{time.DateOnly, PreferredDateLayout(prefs)} {
+		if date, err := time.ParseInLocation(layout, first, now.Location()); err == nil {
+			return date, 1, true
+		}
+	}
+
+	return time.Time{}, 0, false
+}
+
+// parse a time of day like 9am, 9:30pm, 17:00, noon or "at 9 am", returns the number of words consumed
+func parseClock(words []string) (int, int, int, bool) {
+	consumed := 0
+	if len(words) > 0 && strings.ToLower(words[0]) == "at" {
+		consumed = 1
+	}
+	if len(words) <= consumed {
+		return 0, 0, 0, false
+	}
+
+	word := strings.ToLower(strings.Trim(words[consumed], ".,;"))
+	switch word {
+	case "noon":
+		return 12, 0, consumed + 1, true
+	case "midnight":
+		return 0, 0, consumed + 1, true
+	}
+
+	// allow the meridiem as a separate word, e.g. "9 am"
+	if len(words) > consumed+1 {
+		next := strings.ToLower(strings.Trim(words[consumed+1], ".,;"))
+		if (next == "am" || next == "pm") && !strings.HasSuffix(word, "m") {
+			word += next
+			consumed++
+		}
+	}
+
+	match := clockPattern.FindStringSubmatch(word)
+	if match == nil {
+		return 0, 0, 0, false
+	}
+
+	// bare numbers are only times when written as hh:mm
+	if match[2] == "" && match[3] == "" {
+		return 0, 0, 0, false
+	}
+
+	hour, _ := strconv.Atoi(match[1])
+	minute := 0
+	if match[2] != "" {
+		minute, _ = strconv.Atoi(match[2])
+	}
+
+	if match[3] != "" {
+		if hour < 1 || hour > 12 {
+			return 0, 0, 0, false
+		}
+		hour %= 12
+		if match[3] == "pm" {
+			hour += 12
+		}
+	}
+
+	if hour > 23 || minute > 59 {
+		return 0, 0, 0, false
+	}
+
+	return hour, minute, consumed + 1, true
+}
+
+// recurrence rule in a subset of RFC 5545 RRULE
+type rrule struct {
+	freq       string
+	interval   int
+	byDay      []time.Weekday
+	byMonthDay int
+}
+
+func (r rrule) String() string {
+	parts := []string{"FREQ=" + r.freq}
+	if r.interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.interval))
+	}
+	if len(r.byDay) > 0 {
+		days := make([]string, 0, len(r.byDay))
+		for _, d := range r.byDay {
+			days = append(days, rruleDays[d])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.byMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.byMonthDay))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// first date on or after today matching the rule
+func (r rrule) firstOccurrence(now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if len(r.byDay) > 0 {
+		for offset := 0; offset < 7; offset++ {
+			day := today.AddDate(0, 0, offset)
+			for _, d := range r.byDay {
+				if day.Weekday() == d {
+					return day
+				}
+			}
+		}
+	}
+
+	if r.byMonthDay != 0 {
+		for months := 0; months < 12; months++ {
+			month := time.Date(today.Year(), today.Month()+time.Month(months), 1, 0, 0, 0, 0, now.Location())
+			lastDay := month.AddDate(0, 1, -1).Day()
+			day := r.byMonthDay
+			if day < 0 {
+				day = lastDay + 1 + day
+			}
+			if day > lastDay {
+				continue
+			}
+			candidate := time.Date(month.Year(), month.Month(), day, 0, 0, 0, 0, now.Location())
+			if !candidate.Before(today) {
+				return candidate
+			}
+		}
+	}
+
+	return today
+}
+
+// due time on the given day, end of day when no time was given
+func (r rrule) dueOn(day time.Time, hour, minute int) time.Time {
+	if hour < 0 {
+		return endOfDay(day)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
+
+// parse the words following "every", returns the number of words consumed
+func parseRecurrence(words []string) (rrule, int, bool) {
+	if len(words) == 0 {
+		return rrule{}, 0, false
+	}
+
+	rule := rrule{interval: 1}
+	consumed := 0
+	word := strings.ToLower(strings.Trim(words[0], ".,;"))
+
+	// interval like "every 2 weeks" or "every other day"
+	if count, err := strconv.Atoi(word); err == nil && count > 0 && count <= 365 && len(words) > 1 {
+		rule.interval = count
+		consumed = 1
+	} else if word == "other" && len(words) > 1 {
+		rule.interval = 2
+		consumed = 1
+	}
+	if consumed > 0 {
+		word = strings.ToLower(strings.Trim(words[consumed], ".,;"))
+	}
+
+	switch strings.TrimSuffix(word, "s") {
+	case "day":
+		rule.freq = "DAILY"
+		return rule, consumed + 1, true
+	case "week":
+		rule.freq = "WEEKLY"
+		return rule, consumed + 1, true
+	case "month":
+		rule.freq = "MONTHLY"
+		return rule, consumed + 1, true
+	case "year":
+		rule.freq = "YEARLY"
+		return rule, consumed + 1, true
+	case "weekday":
+		rule.freq = "WEEKLY"
+		rule.byDay = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+		return rule, consumed + 1, true
+	}
+
+	if day, ok := weekdays[word]; ok {
+		rule.freq = "WEEKLY"
+		rule.byDay = []time.Weekday{day}
+		return rule, consumed + 1, true
+	}
+
+	// day of month like "every 1st of month", "every 15th" or "every last day of the month"
+	if consumed > 0 {
+		return rrule{}, 0, false
+	}
+
+	monthDay := 0
+	if match := ordinalPattern.FindStringSubmatch(word); match != nil {
+		monthDay, _ = strconv.Atoi(match[1])
+		if monthDay < 1 || monthDay > 31 {
+			return rrule{}, 0, false
+		}
+	} else if word == "last" {
+		monthDay = -1
+	} else {
+		return rrule{}, 0, false
+	}
+	consumed = 1
+
+	for _, optional := range []string{"day", "of", "the", "month"} {
+		if len(words) > consumed && strings.ToLower(strings.Trim(words[consumed], ".,;")) == optional {
+			consumed++
+		}
+	}
+	if monthDay == -1 && consumed == 1 {
+		return rrule{}, 0, false
+	}
+
+	rule.freq = "MONTHLY"
+	rule.byMonthDay = monthDay
+	return rule, consumed, true
+}
+
+// first occurrence of weekday after today
+func nextWeekday(today time.Time, day time.Weekday) time.Time {
+	offset := (int(day) - int(today.Weekday()) + 7) % 7
+	if offset == 0 {
+		offset = 7
+	}
+	return today.AddDate(0, 0, offset)
+}
+
+func endOfDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, date.Location())
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"reflect"
+	"task_manager/models"
+	"testing"
+	"time"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	// a Wednesday morning
+	now := time.Date(2024, time.May, 15, 10, 0, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute, second int) *time.Time {
+		due := time.Date(2024, month, day, hour, minute, second, 0, time.UTC)
+		return &due
+	}
+	endOf := func(month time.Month, day int) *time.Time {
+		return at(month, day, 23, 59, 59)
+	}
+
+	tests := []struct {
+		name  string
+		text  string
+		prefs models.UserPreferences
+		want  models.QuickAddResult
+	}{
+		{
+			name: "title only",
+			text: "Buy milk",
+			want: models.QuickAddResult{Title: "Buy milk", Tags: []string{}},
+		},
+		{
+			name: "priority tag and due time",
+			text: "Pay rent !high #finance due tomorrow 9am",
+			want: models.QuickAddResult{Title: "Pay rent", Priority: "high", Tags: []string{"finance"}, DueDate: at(time.May, 16, 9, 0, 0)},
+		},
+		{
+			name: "numeric priority",
+			text: "Call mom !1",
+			want: models.QuickAddResult{Title: "Call mom", Priority: "urgent", Tags: []string{}},
+		},
+		{
+			name: "unknown priority stays in the title",
+			text: "Wow !amazing",
+			want: models.QuickAddResult{Title: "Wow !amazing", Tags: []string{}},
+		},
+		{
+			name: "comma separated tags are split and deduplicated",
+			text: "Plan trip #Travel,family #travel. #,",
+			want: models.QuickAddResult{Title: "Plan trip #,", Tags: []string{"travel", "family"}},
+		},
+		{
+			name: "weekday",
+			text: "Report due friday",
+			want: models.QuickAddResult{Title: "Report", Tags: []string{}, DueDate: endOf(time.May, 17)},
+		},
+		{
+			name: "same weekday is next week",
+			text: "Report due wed",
+			want: models.QuickAddResult{Title: "Report", Tags: []string{}, DueDate: endOf(time.May, 22)},
+		},
+		{
+			name: "next week starts on monday by default",
+			text: "Report due next week",
+			want: models.QuickAddResult{Title: "Report", Tags: []string{}, DueDate: endOf(time.May, 20)},
+		},
+		{
+			name:  "next week on the preferred week start",
+			text:  "Report due next week",
+			prefs: models.UserPreferences{WeekStart: "sunday"},
+			want:  models.QuickAddResult{Title: "Report", Tags: []string{}, DueDate: endOf(time.May, 19)},
+		},
+		{
+			name: "relative days",
+			text: "Report due in 3 days",
+			want: models.QuickAddResult{Title: "Report", Tags: []string{}, DueDate: endOf(time.May, 18)},
+		},
+		{
+			name: "iso date with 24 hour time",
+			text: "Report due 2024-06-01 17:00",
+			want: models.QuickAddResult{Title: "Report", Tags: []string{}, DueDate: at(time.June, 1, 17, 0, 0)},
+		},
+		{
+			name:  "preferred date format",
+			text:  "Report due 01/06/2024",
+			prefs: models.UserPreferences{DateFormat: "DD/MM/YYYY"},
+			want:  models.QuickAddResult{Title: "Report", Tags: []string{}, DueDate: endOf(time.June, 1)},
+		},
+		{
+			name: "time only is today",
+			text: "Lunch due at noon",
+			want: models.QuickAddResult{Title: "Lunch", Tags: []string{}, DueDate: at(time.May, 15, 12, 0, 0)},
+		},
+		{
+			name: "meridiem as separate word",
+			text: "Call on tomorrow at 9 pm",
+			want: models.QuickAddResult{Title: "Call", Tags: []string{}, DueDate: at(time.May, 16, 21, 0, 0)},
+		},
+		{
+			name: "due without a date stays in the title",
+			text: "Bills due",
+			want: models.QuickAddResult{Title: "Bills due", Tags: []string{}},
+		},
+		{
+			name: "bare number is not a time",
+			text: "Order 5 pizzas",
+			want: models.QuickAddResult{Title: "Order 5 pizzas", Tags: []string{}},
+		},
+		{
+			name: "weekdays with a passed time start tomorrow",
+			text: "Standup every weekday 9:30am",
+			want: models.QuickAddResult{Title: "Standup", Tags: []string{}, Recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", DueDate: at(time.May, 16, 9, 30, 0)},
+		},
+		{
+			name: "weekly on a weekday",
+			text: "Sync every monday at 8am",
+			want: models.QuickAddResult{Title: "Sync", Tags: []string{}, Recurrence: "FREQ=WEEKLY;BYDAY=MO", DueDate: at(time.May, 20, 8, 0, 0)},
+		},
+		{
+			name: "interval",
+			text: "Gym every 2 weeks",
+			want: models.QuickAddResult{Title: "Gym", Tags: []string{}, Recurrence: "FREQ=WEEKLY;INTERVAL=2", DueDate: endOf(time.May, 15)},
+		},
+		{
+			name: "every other day",
+			text: "Water plants every other day",
+			want: models.QuickAddResult{Title: "Water plants", Tags: []string{}, Recurrence: "FREQ=DAILY;INTERVAL=2", DueDate: endOf(time.May, 15)},
+		},
+		{
+			name: "day of month",
+			text: "Pay rent every 1st of month !high #finance",
+			want: models.QuickAddResult{Title: "Pay rent", Priority: "high", Tags: []string{"finance"}, Recurrence: "FREQ=MONTHLY;BYMONTHDAY=1", DueDate: endOf(time.June, 1)},
+		},
+		{
+			name: "last day of month",
+			text: "Review every last day of the month",
+			want: models.QuickAddResult{Title: "Review", Tags: []string{}, Recurrence: "FREQ=MONTHLY;BYMONTHDAY=-1", DueDate: endOf(time.May, 31)},
+		},
+		{
+			name: "explicit due date wins over the first occurrence",
+			text: "Report every week due 2024-05-20",
+			want: models.QuickAddResult{Title: "Report", Tags: []string{}, Recurrence: "FREQ=WEEKLY", DueDate: endOf(time.May, 20)},
+		},
+		{
+			name: "every without a rule stays in the title",
+			text: "Try every trick",
+			want: models.QuickAddResult{Title: "Try every trick", Tags: []string{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuickAdd(tt.text, now, tt.prefs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQuickAdd(%q)\n got  %+v\n want %+v", tt.text, describe(got), describe(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseQuickAddRequiresTitle(t *testing.T) {
+	_, err := ParseQuickAdd("!high #home due tomorrow", time.Now(), models.UserPreferences{})
+	if err == nil {
+		t.Fatal("expected an error for text without a title")
+	}
+}
+
+// result with the due date formatted so failures are readable
+func describe(result models.QuickAddResult) map[string]interface{} {
+	due := "none"
+	if result.DueDate != nil {
+		due = result.DueDate.Format(time.DateTime)
+	}
+
+	return map[string]interface{}{
+		"title":      result.Title,
+		"priority":   result.Priority,
+		"tags":       result.Tags,
+		"due":        due,
+		"recurrence": result.Recurrence,
+	}
+}
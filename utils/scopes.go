@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// Scopes a token can be restricted to
+var KnownScopes = []string{"tasks:read", "tasks:write", "user:read", "user:write"}
+
+// Scopes of a read-only token
+var ReadOnlyScopes = []string{"tasks:read", "user:read"}
+
+// Scopes granted to a signed in session
+func SessionScopes(readOnly bool) []string {
+	if readOnly {
+		return ReadOnlyScopes
+	}
+
+	return KnownScopes
+}
+
+// Split a space separated scope claim
+func ParseScopes(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// Required scopes that were not granted
+func MissingScopes(granted, required []string) []string {
+	missing := []string{}
+	for _, scope := range required {
+		found := false
+		for _, g := range granted {
+			if g == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+// Validates requested scopes, an empty list grants every scope
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		known := false
+		for _, k := range KnownScopes {
+			if scope == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return errors.New("unknown scope " + scope + ", must be one of " + strings.Join(KnownScopes, ", "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Cookies of the cookie session mode. The __Host- prefix keeps other subdomains
+// from setting them, the refresh cookie is scoped to the refresh route instead.
+const (
+	SessionCookie = "__Host-session"
+	RefreshCookie = "__Secure-refresh"
+	CSRFCookie    = "__Host-csrf"
+	CSRFHeader    = "X-CSRF-Token"
+	// path the refresh cookie is sent to
+	RefreshCookiePath = "/user/refresh"
+)
+
+// Whether clients may ask for tokens as cookies, set by the COOKIE_SESSIONS env variable
+func CookieSessionsEnabled() bool {
+	return os.Getenv("COOKIE_SESSIONS") == "true"
+}
+
+// SameSite attribute of session cookies from the COOKIE_SAMESITE env variable
+func CookieSameSite() http.SameSite {
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+
+	return http.SameSiteLaxMode
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// base32 of the ASCII secret "12345678901234567890" used by the RFC 6238 test vectors
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, last 6 of the 8 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		at := time.Unix(tt.unix, 0)
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, at)
+		if !ok {
+			t.Errorf("code %s at %d rejected", tt.code, tt.unix)
+			continue
+		}
+		if step != tt.unix/totpPeriod {
+			t.Errorf("step of code %s = %d, want %d", tt.code, step, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	// code 287082 belongs to step 1 (30s to 59s)
+	const code = "287082"
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		unix     int64
+		wantOK   bool
+		wantStep int64
+	}{
+		{name: "current step", secret: rfc6238Secret, code: code, unix: 45, wantOK: true, wantStep: 1},
+		{name: "one step late", secret: rfc6238Secret, code: code, unix: 75, wantOK: true, wantStep: 1},
+		{name: "one step early", secret: rfc6238Secret, code: code, unix: 15, wantOK: true, wantStep: 1},
+		{name: "two steps late", secret: rfc6238Secret, code: code, unix: 105},
+		{name: "two steps early", secret: rfc6238Secret, code: "050471", unix: 1111111051},
+		{name: "spaces are ignored", secret: rfc6238Secret, code: "287 082", unix: 45, wantOK: true, wantStep: 1},
+		{name: "lower case secret", secret: strings.ToLower(rfc6238Secret), code: code, unix: 45, wantOK: true, wantStep: 1},
+		{name: "wrong code", secret: rfc6238Secret, code: "287083", unix: 45},
+		{name: "too short", secret: rfc6238Secret, code: "28708", unix: 45},
+		{name: "eight digits", secret: rfc6238Secret, code: "94287082", unix: 45},
+		{name: "invalid secret", secret: "not base32!", code: code, unix: 45},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(tt.secret, tt.code, time.Unix(tt.unix, 0))
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("ValidateTOTP() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPReplay(t *testing.T) {
+	// the same code matches one step whenever it is checked within the skew, so
+	// recording the step as used rejects every replay of it
+	const code = "287082"
+	var used int64
+	for _, unix := range []int64{30, 45, 59, 75, 89} {
+		step, ok := ValidateTOTP(rfc6238Secret, code, time.Unix(unix, 0))
+		if !ok {
+			t.Fatalf("code rejected at %d", unix)
+		}
+		if used != 0 && step != used {
+			t.Errorf("code matched step %d at %d, first matched %d", step, unix, used)
+		}
+		used = step
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q does not decode to 20 bytes: %v", secret, err)
+	}
+
+	now := time.Now()
+	if _, ok := ValidateTOTP(secret, hotp(key, now.Unix()/totpPeriod), now); !ok {
+		t.Error("code of the generated secret rejected")
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 200 {
+		t.Fatalf("%d codes, want 200", len(codes))
+	}
+
+	format := regexp.MustCompile(`^[abcdefghjkmnpqrstuvwxyz23456789]{4}-[abcdefghjkmnpqrstuvwxyz23456789]{4}$`)
+	seen := map[string]bool{}
+	counts := map[rune]int{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q has an unexpected format", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+
+		for _, r := range strings.ReplaceAll(code, "-", "") {
+			counts[r]++
+		}
+		if NormalizeRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", " "))) != code {
+			t.Errorf("typed code %q does not normalize back", code)
+		}
+	}
+
+	// 1600 characters over 31 symbols, about 52 each; every symbol has to show up
+	if len(counts) != 31 {
+		t.Errorf("%d of 31 symbols used", len(counts))
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"regexp"
 	"strings"
-	"unicode"
 )
 
 // ValidateDetails validates name, email, mobile, gender, and password.
@@ -30,25 +29,8 @@ func ValidateDetails(name, email, mobile, gender, password string) error {
 		return errors.New("gender must be male, female or other")
 	}
 	// Validate password
-	var hasUpper, hasLower, hasDigit, hasSpecial bool
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsDigit(char):
-			hasDigit = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
-	}
-	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
-	}
-	if !hasUpper || !hasLower || !hasDigit || !hasSpecial {
-
-		return errors.New("password must contain at least one uppercase letter, one lowercase letter, one digit, and one special character")
+	if err := GetPasswordPolicy().Check(password, email, name); err != nil {
+		return errors.New("password " + err.Error())
 	}
 	return nil
 
@@ -73,49 +55,30 @@ func ValidateUser(name string, mobileno string) error {
 	return nil
 }
 
-// ValidatePassword checks if both old and new passwords meet complexity rules
-func ValidatePassword(oldPassword, newPassword string) error {
-	// Validate old password
-	if err := checkPasswordComplexity(oldPassword); err != nil {
+// ValidatePassword checks the old password and whether the new one meets the password policy
+func ValidatePassword(oldPassword, newPassword string, personal ...string) error {
+	policy := GetPasswordPolicy()
+
+	// Validate old password, it was set under whatever policy applied at the time
+	if err := policy.CheckLength(oldPassword); err != nil {
 		return errors.New("old password: " + err.Error())
 	}
 
 	// Validate new password
-	if err := checkPasswordComplexity(newPassword); err != nil {
+	if err := policy.Check(newPassword, personal...); err != nil {
 		return errors.New("new password: " + err.Error())
 	}
 
 	return nil // Both passwords are valid
 }
 
-// checkPasswordComplexity enforces password strength rules
-func checkPasswordComplexity(password string) error {
-	var hasUpper, hasLower, hasDigit, hasSpecial bool
-
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsDigit(char):
-			hasDigit = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
-	}
-
-	// Ensure password is at least 8 characters long
-	if len(password) < 8 {
-		return errors.New("must be at least 8 characters long")
-	}
-
-	// Ensure all complexity rules are met
-	if !hasUpper || !hasLower || !hasDigit || !hasSpecial {
-		return errors.New("must contain at least one uppercase letter, one lowercase letter, one digit, and one special character")
+// ValidateNewPassword checks if the new password meets the password policy
+func ValidateNewPassword(newPassword string, personal ...string) error {
+	if err := GetPasswordPolicy().Check(newPassword, personal...); err != nil {
+		return errors.New("new password: " + err.Error())
 	}
 
-	return nil // Password is valid
+	return nil
 }
 
 func ValidateLoginDetails(email, password string) error {
@@ -123,26 +86,20 @@ func ValidateLoginDetails(email, password string) error {
 	if !regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`).MatchString(email) {
 		return errors.New("invalid email format")
 	}
-	// Validate password
-	var hasUpper, hasLower, hasDigit, hasSpecial bool
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsDigit(char):
-			hasDigit = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
-	}
-	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
-	}
-	if !hasUpper || !hasLower || !hasDigit || !hasSpecial {
+	// Validate password, the policy is checked once the credentials are verified
+	if err := GetPasswordPolicy().CheckLength(password); err != nil {
+		return errors.New("password " + err.Error())
+	}
+	return nil
+}
 
-		return errors.New("password must contain at least one uppercase letter, one lowercase letter, one digit, and one special character")
+// ValidateEmailChange checks the new email address differs from the current one
+func ValidateEmailChange(newEmail, currentEmail string) error {
+	if !regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`).MatchString(newEmail) {
+		return errors.New("invalid email format")
+	}
+	if strings.EqualFold(newEmail, currentEmail) {
+		return errors.New("new email must differ from the current email")
 	}
 	return nil
 }
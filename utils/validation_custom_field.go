@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"task_manager/models"
+	"time"
+)
+
+// Validate custom field name, type and select options
+func ValidateCustomField(field models.CustomField) error {
+	if !regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,49}$`).MatchString(field.Name) {
+		return errors.New("field name must start with a letter and contain only letters, digits and underscores (max 50)")
+	}
+
+	switch field.Type {
+	case models.FieldTypeSingleSelect, models.FieldTypeMultiSelect:
+		if len(field.Options) == 0 {
+			return errors.New("select fields must have at least one option")
+		}
+		seen := map[string]bool{}
+		for _, option := range field.Options {
+			if strings.TrimSpace(option) == "" {
+				return errors.New("select options must not be empty")
+			}
+			if seen[option] {
+				return errors.New("select options must be unique")
+			}
+			seen[option] = true
+		}
+	case models.FieldTypeText, models.FieldTypeNumber, models.FieldTypeDate, models.FieldTypeCheckbox, models.FieldTypeURL:
+		if len(field.Options) != 0 {
+			return errors.New("only select fields can have options")
+		}
+	default:
+		return errors.New("field type must be text, number, date, single_select, multi_select, checkbox or url")
+	}
+
+	return nil
+}
+
+// Validate a custom field value from a request body and convert it to its stored form
+func ParseCustomFieldValue(field models.CustomField, raw interface{}) (models.CustomFieldValue, error) {
+	var value models.CustomFieldValue
+
+	switch field.Type {
+	case models.FieldTypeText:
+		text, ok := raw.(string)
+		if !ok {
+			return value, errors.New(field.Name + ": must be a string")
+		}
+		if len(text) > 1000 {
+			return value, errors.New(field.Name + ": must be at most 1000 characters long")
+		}
+		value.Value = text
+
+	case models.FieldTypeNumber:
+		number, ok := raw.(float64)
+		if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+			return value, errors.New(field.Name + ": must be a number")
+		}
+		value.Value = strconv.FormatFloat(number, 'f', -1, 64)
+		value.NumberValue = &number
+
+	case models.FieldTypeDate:
+		text, ok := raw.(string)
+		if !ok {
+			return value, errors.New(field.Name + ": must be a date in YYYY-MM-DD format")
+		}
+		date, err := time.Parse(time.DateOnly, text)
+		if err != nil {
+			return value, errors.New(field.Name + ": must be a date in YYYY-MM-DD format")
+		}
+		value.Value = text
+		value.DateValue = &date
+
+	case models.FieldTypeSingleSelect:
+		text, ok := raw.(string)
+		if !ok || !hasOption(field.Options, text) {
+			return value, errors.New(field.Name + ": must be one of " + strings.Join(field.Options, ", "))
+		}
+		value.Value = text
+
+	case models.FieldTypeMultiSelect:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return value, errors.New(field.Name + ": must be a list of options")
+		}
+		selected := []string{}
+		for _, item := range items {
+			text, ok := item.(string)
+			if !ok || !hasOption(field.Options, text) {
+				return value, errors.New(field.Name + ": options must be any of " + strings.Join(field.Options, ", "))
+			}
+			if !hasOption(selected, text) {
+				selected = append(selected, text)
+			}
+		}
+		encoded, _ := json.Marshal(selected)
+		value.Value = string(encoded)
+
+	case models.FieldTypeCheckbox:
+		checked, ok := raw.(bool)
+		if !ok {
+			return value, errors.New(field.Name + ": must be true or false")
+		}
+		number := 0.0
+		if checked {
+			number = 1
+		}
+		value.Value = strconv.FormatBool(checked)
+		value.NumberValue = &number
+
+	case models.FieldTypeURL:
+		text, ok := raw.(string)
+		if !ok {
+			return value, errors.New(field.Name + ": must be a url")
+		}
+		parsed, err := url.ParseRequestURI(text)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return value, errors.New(field.Name + ": must be an http or https url")
+		}
+		value.Value = text
+
+	default:
+		return value, errors.New(field.Name + ": unsupported field type")
+	}
+
+	return value, nil
+}
+
+// Convert a custom field filter from a query parameter to its stored form
+func ParseCustomFieldFilter(field models.CustomField, raw string) (models.CustomFieldValue, error) {
+	switch field.Type {
+	case models.FieldTypeNumber:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return models.CustomFieldValue{}, errors.New(field.Name + ": must be a number")
+		}
+		return ParseCustomFieldValue(field, number)
+	case models.FieldTypeCheckbox:
+		checked, err := strconv.ParseBool(raw)
+		if err != nil {
+			return models.CustomFieldValue{}, errors.New(field.Name + ": must be true or false")
+		}
+		return ParseCustomFieldValue(field, checked)
+	case models.FieldTypeMultiSelect:
+		// multi select filters match tasks having the given option selected
+		if !hasOption(field.Options, raw) {
+			return models.CustomFieldValue{}, errors.New(field.Name + ": must be one of " + strings.Join(field.Options, ", "))
+		}
+		return models.CustomFieldValue{Value: raw}, nil
+	}
+
+	return ParseCustomFieldValue(field, raw)
+}
+
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
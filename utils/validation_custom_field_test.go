@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"task_manager/models"
+	"testing"
+	"time"
+)
+
+var testFields = map[string]models.CustomField{
+	models.FieldTypeText:         {Name: "notes", Type: models.FieldTypeText},
+	models.FieldTypeNumber:       {Name: "points", Type: models.FieldTypeNumber},
+	models.FieldTypeDate:         {Name: "starts", Type: models.FieldTypeDate},
+	models.FieldTypeSingleSelect: {Name: "stage", Type: models.FieldTypeSingleSelect, Options: []string{"todo", "done"}},
+	models.FieldTypeMultiSelect:  {Name: "labels", Type: models.FieldTypeMultiSelect, Options: []string{"bug", "ui", `say "hi"`}},
+	models.FieldTypeCheckbox:     {Name: "billable", Type: models.FieldTypeCheckbox},
+	models.FieldTypeURL:          {Name: "link", Type: models.FieldTypeURL},
+}
+
+// stored form of a value, nil pointers when the column is not used
+type storedValue struct {
+	value  string
+	number *float64
+	date   string
+}
+
+func number(n float64) *float64 {
+	return &n
+}
+
+func checkStoredValue(t *testing.T, got models.CustomFieldValue, want storedValue) {
+	t.Helper()
+
+	if got.Value != want.value {
+		t.Errorf("value = %q, want %q", got.Value, want.value)
+	}
+	if (got.NumberValue == nil) != (want.number == nil) || (got.NumberValue != nil && *got.NumberValue != *want.number) {
+		t.Errorf("number value = %v, want %v", got.NumberValue, want.number)
+	}
+	date := ""
+	if got.DateValue != nil {
+		date = got.DateValue.Format(time.DateOnly)
+	}
+	if date != want.date {
+		t.Errorf("date value = %q, want %q", date, want.date)
+	}
+}
+
+func TestParseCustomFieldValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldType string
+		raw       interface{}
+		want      storedValue
+		wantErr   string
+	}{
+		{name: "text", fieldType: models.FieldTypeText, raw: "call back", want: storedValue{value: "call back"}},
+		{name: "text too long", fieldType: models.FieldTypeText, raw: strings.Repeat("a", 1001), wantErr: "at most 1000"},
+		{name: "text not a string", fieldType: models.FieldTypeText, raw: 1.0, wantErr: "must be a string"},
+		{name: "number", fieldType: models.FieldTypeNumber, raw: 2.5, want: storedValue{value: "2.5", number: number(2.5)}},
+		{name: "whole number", fieldType: models.FieldTypeNumber, raw: 3.0, want: storedValue{value: "3", number: number(3)}},
+		{name: "number as string", fieldType: models.FieldTypeNumber, raw: "3", wantErr: "must be a number"},
+		{name: "not a number", fieldType: models.FieldTypeNumber, raw: math.NaN(), wantErr: "must be a number"},
+		{name: "infinite number", fieldType: models.FieldTypeNumber, raw: math.Inf(1), wantErr: "must be a number"},
+		{name: "date", fieldType: models.FieldTypeDate, raw: "2024-05-15", want: storedValue{value: "2024-05-15", date: "2024-05-15"}},
+		{name: "date in another format", fieldType: models.FieldTypeDate, raw: "15/05/2024", wantErr: "YYYY-MM-DD"},
+		{name: "date not a string", fieldType: models.FieldTypeDate, raw: 20240515.0, wantErr: "YYYY-MM-DD"},
+		{name: "single select", fieldType: models.FieldTypeSingleSelect, raw: "done", want: storedValue{value: "done"}},
+		{name: "single select unknown option", fieldType: models.FieldTypeSingleSelect, raw: "doing", wantErr: "must be one of todo, done"},
+		{name: "multi select", fieldType: models.FieldTypeMultiSelect, raw: []interface{}{"ui", "bug", "ui"}, want: storedValue{value: `["ui","bug"]`}},
+		{name: "multi select quotes", fieldType: models.FieldTypeMultiSelect, raw: []interface{}{`say "hi"`}, want: storedValue{value: `["say \"hi\""]`}},
+		{name: "multi select empty", fieldType: models.FieldTypeMultiSelect, raw: []interface{}{}, want: storedValue{value: `[]`}},
+		{name: "multi select unknown option", fieldType: models.FieldTypeMultiSelect, raw: []interface{}{"bug", "ux"}, wantErr: "options must be any of"},
+		{name: "multi select not a list", fieldType: models.FieldTypeMultiSelect, raw: "bug", wantErr: "must be a list"},
+		{name: "checkbox checked", fieldType: models.FieldTypeCheckbox, raw: true, want: storedValue{value: "true", number: number(1)}},
+		{name: "checkbox unchecked", fieldType: models.FieldTypeCheckbox, raw: false, want: storedValue{value: "false", number: number(0)}},
+		{name: "checkbox as string", fieldType: models.FieldTypeCheckbox, raw: "true", wantErr: "true or false"},
+		{name: "url", fieldType: models.FieldTypeURL, raw: "https://example.com/a?b=c", want: storedValue{value: "https://example.com/a?b=c"}},
+		{name: "url other scheme", fieldType: models.FieldTypeURL, raw: "ftp://example.com", wantErr: "http or https"},
+		{name: "url without host", fieldType: models.FieldTypeURL, raw: "https:///path", wantErr: "http or https"},
+		{name: "relative url", fieldType: models.FieldTypeURL, raw: "example.com", wantErr: "http or https"},
+		{name: "unknown type", fieldType: "color", raw: "red", wantErr: "unsupported field type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field, ok := testFields[tt.fieldType]
+			if !ok {
+				field = models.CustomField{Name: "other", Type: tt.fieldType}
+			}
+
+			got, err := ParseCustomFieldValue(field, tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.HasPrefix(err.Error(), field.Name+": ") {
+					t.Errorf("error = %v, want %q prefixed by the field name", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkStoredValue(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseCustomFieldFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldType string
+		raw       string
+		want      storedValue
+		wantErr   string
+	}{
+		{name: "text", fieldType: models.FieldTypeText, raw: "call back", want: storedValue{value: "call back"}},
+		{name: "number", fieldType: models.FieldTypeNumber, raw: "2.50", want: storedValue{value: "2.5", number: number(2.5)}},
+		{name: "number not numeric", fieldType: models.FieldTypeNumber, raw: "two", wantErr: "must be a number"},
+		{name: "number not finite", fieldType: models.FieldTypeNumber, raw: "Inf", wantErr: "must be a number"},
+		{name: "date", fieldType: models.FieldTypeDate, raw: "2024-05-15", want: storedValue{value: "2024-05-15", date: "2024-05-15"}},
+		{name: "date invalid", fieldType: models.FieldTypeDate, raw: "2024-13-01", wantErr: "YYYY-MM-DD"},
+		{name: "single select", fieldType: models.FieldTypeSingleSelect, raw: "todo", want: storedValue{value: "todo"}},
+		{name: "single select unknown option", fieldType: models.FieldTypeSingleSelect, raw: "later", wantErr: "must be one of"},
+		// a multi select filter names one option, matched as an element of the stored list
+		{name: "multi select option", fieldType: models.FieldTypeMultiSelect, raw: "bug", want: storedValue{value: "bug"}},
+		{name: "multi select quoted option", fieldType: models.FieldTypeMultiSelect, raw: `say "hi"`, want: storedValue{value: `say "hi"`}},
+		{name: "multi select pattern", fieldType: models.FieldTypeMultiSelect, raw: "%", wantErr: "must be one of bug, ui"},
+		{name: "multi select list", fieldType: models.FieldTypeMultiSelect, raw: `["bug"]`, wantErr: "must be one of"},
+		{name: "checkbox", fieldType: models.FieldTypeCheckbox, raw: "1", want: storedValue{value: "true", number: number(1)}},
+		{name: "checkbox false", fieldType: models.FieldTypeCheckbox, raw: "false", want: storedValue{value: "false", number: number(0)}},
+		{name: "checkbox invalid", fieldType: models.FieldTypeCheckbox, raw: "yes", wantErr: "true or false"},
+		{name: "url", fieldType: models.FieldTypeURL, raw: "http://localhost:8080", want: storedValue{value: "http://localhost:8080"}},
+		{name: "url invalid", fieldType: models.FieldTypeURL, raw: "javascript:alert(1)", wantErr: "http or https"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := testFields[tt.fieldType]
+
+			got, err := ParseCustomFieldFilter(field, tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkStoredValue(t, got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"task_manager/models"
+)
+
+// unreserved characters of a PKCE code verifier (RFC 7636 section 4.1)
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// Grant types a client can register for
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// Validates an OAuth client registration
+func ValidateClientRegistration(req *models.RegisterClientRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("client name is required")
+	}
+	if len(req.Name) > 100 {
+		return errors.New("client name must be at most 100 characters")
+	}
+
+	if len(req.GrantTypes) == 0 {
+		req.GrantTypes = []string{GrantAuthorizationCode}
+	}
+	for _, grant := range req.GrantTypes {
+		switch grant {
+		case GrantAuthorizationCode:
+		case GrantClientCredentials:
+			//client credentials need a secret to authenticate with
+			if req.Public {
+				return errors.New("public clients cannot use the client_credentials grant")
+			}
+		default:
+			return errors.New("unsupported grant type " + grant)
+		}
+	}
+
+	if hasGrant(req.GrantTypes, GrantAuthorizationCode) && len(req.RedirectURIs) == 0 {
+		return errors.New("at least one redirect uri is required")
+	}
+	for _, uri := range req.RedirectURIs {
+		if err := ValidateRedirectURI(uri); err != nil {
+			return err
+		}
+	}
+
+	if len(req.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+
+	return ValidateScopes(req.Scopes)
+}
+
+// Redirect uris must be absolute without fragment, plain http is only allowed for loopback
+func ValidateRedirectURI(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		return errors.New("redirect uri " + uri + " must be an absolute url")
+	}
+	if parsed.Fragment != "" {
+		return errors.New("redirect uri " + uri + " must not contain a fragment")
+	}
+
+	host := parsed.Hostname()
+	loopback := host == "localhost" || host == "127.0.0.1" || host == "::1"
+	if parsed.Scheme != "https" && !(parsed.Scheme == "http" && loopback) {
+		return errors.New("redirect uri " + uri + " must use https")
+	}
+
+	return nil
+}
+
+// Scopes granted for a space separated scope request, an empty request asks for every allowed scope
+func ResolveRequestedScopes(scope string, allowed []string) ([]string, error) {
+	requested := ParseScopes(scope)
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+
+	if missing := MissingScopes(allowed, requested); len(missing) > 0 {
+		return nil, errors.New("scope " + strings.Join(missing, ", ") + " is not allowed for this client")
+	}
+
+	return requested, nil
+}
+
+// Validates a PKCE code verifier, 43 to 128 unreserved characters
+func ValidateCodeVerifier(verifier string) error {
+	if verifier == "" {
+		return errors.New("code_verifier is required")
+	}
+	if !codeVerifierPattern.MatchString(verifier) {
+		return errors.New("code_verifier must be 43 to 128 characters of A-Z, a-z, 0-9, -, ., _ or ~")
+	}
+
+	return nil
+}
+
+// Whether the code verifier matches the S256 code challenge
+func VerifyCodeChallenge(verifier, challenge string) bool {
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier)), []byte(challenge)) == 1
+}
+
+func hasGrant(grants []string, grant string) bool {
+	for _, g := range grants {
+		if g == grant {
+			return true
+		}
+	}
+
+	return false
+}
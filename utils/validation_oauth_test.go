@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCodeVerifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		wantErr  bool
+	}{
+		{name: "shortest", verifier: strings.Repeat("a", 43)},
+		{name: "longest", verifier: strings.Repeat("a", 128)},
+		{name: "every unreserved character", verifier: "ABCXYZabcxyz0189-._~" + strings.Repeat("a", 23)},
+		{name: "empty", verifier: "", wantErr: true},
+		{name: "too short", verifier: strings.Repeat("a", 42), wantErr: true},
+		{name: "too long", verifier: strings.Repeat("a", 129), wantErr: true},
+		{name: "reserved character", verifier: strings.Repeat("a", 42) + "+", wantErr: true},
+		{name: "space", verifier: strings.Repeat("a", 42) + " ", wantErr: true},
+		{name: "non ascii", verifier: strings.Repeat("a", 42) + "é", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCodeVerifier(tt.verifier)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCodeVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := PKCEChallenge(verifier); got != challenge {
+		t.Errorf("PKCEChallenge() = %q, want %q", got, challenge)
+	}
+	if !VerifyCodeChallenge(verifier, challenge) {
+		t.Error("verifier of the challenge rejected")
+	}
+	if VerifyCodeChallenge(verifier[1:]+"A", challenge) {
+		t.Error("other verifier accepted")
+	}
+	//the plain method is not supported
+	if VerifyCodeChallenge(verifier, verifier) {
+		t.Error("plain challenge accepted")
+	}
+
+	generated, generatedChallenge, err := GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateCodeVerifier(generated); err != nil {
+		t.Errorf("generated verifier is invalid: %v", err)
+	}
+	if !VerifyCodeChallenge(generated, generatedChallenge) {
+		t.Error("generated verifier does not match its challenge")
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"task_manager/models"
+	"time"
+)
+
+// Go layouts of the supported date formats
+var dateLayouts = map[string]string{
+	"YYYY-MM-DD": time.DateOnly,
+	"DD/MM/YYYY": "02/01/2006",
+	"MM/DD/YYYY": "01/02/2006",
+	"DD.MM.YYYY": "02.01.2006",
+}
+
+var weekStarts = map[string]time.Weekday{
+	"saturday": time.Saturday,
+	"sunday":   time.Sunday,
+	"monday":   time.Monday,
+}
+
+// Validate user preferences
+func ValidatePreferences(prefs models.UserPreferences) error {
+	if _, err := time.LoadLocation(prefs.Timezone); err != nil || prefs.Timezone == "" || prefs.Timezone == "Local" {
+		return errors.New("timezone must be a valid IANA timezone")
+	}
+
+	if _, ok := weekStarts[prefs.WeekStart]; !ok {
+		return errors.New("week start must be saturday, sunday or monday")
+	}
+
+	if _, ok := dateLayouts[prefs.DateFormat]; !ok {
+		return errors.New("date format must be YYYY-MM-DD, DD/MM/YYYY, MM/DD/YYYY or DD.MM.YYYY")
+	}
+
+	if prefs.DefaultSort != "asc" && prefs.DefaultSort != "desc" {
+		return errors.New("default sort must be asc or desc")
+	}
+
+	if prefs.DefaultPageSize < 1 || prefs.DefaultPageSize > 100 {
+		return errors.New("default page size must be between 1 and 100")
+	}
+
+	return nil
+}
+
+// Location of the preferred timezone, UTC when it cannot be loaded
+func PreferredLocation(prefs models.UserPreferences) *time.Location {
+	location, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Go layout of the preferred date format
+func PreferredDateLayout(prefs models.UserPreferences) string {
+	if layout, ok := dateLayouts[prefs.DateFormat]; ok {
+		return layout
+	}
+	return time.DateOnly
+}
+
+// First day of the week according to preferences
+func PreferredWeekStart(prefs models.UserPreferences) time.Weekday {
+	if day, ok := weekStarts[prefs.WeekStart]; ok {
+		return day
+	}
+	return time.Monday
+}
@@ -0,0 +1,24 @@
+package utils
+
+import "errors"
+
+// Validate task estimate, estimate unit and remaining effort
+func ValidateTaskEffort(estimate int, unit string, remaining int) error {
+	if unit != "points" && unit != "minutes" {
+		return errors.New("estimate unit must be points or minutes")
+	}
+
+	if estimate < 0 {
+		return errors.New("estimate must not be negative")
+	}
+
+	if remaining < 0 {
+		return errors.New("remaining effort must not be negative")
+	}
+
+	if remaining > estimate {
+		return errors.New("remaining effort must not exceed the estimate")
+	}
+
+	return nil
+}
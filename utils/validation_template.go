@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"task_manager/models"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z][a-zA-Z0-9_]*)\s*\}\}`)
+
+// Validate template name and items
+func ValidateTemplate(t *models.TaskTemplate) error {
+	if len(strings.TrimSpace(t.Name)) < 2 || len(t.Name) > 100 {
+		return errors.New("template name must be between 2 and 100 characters long")
+	}
+
+	if len(t.Items) == 0 || len(t.Items) > 100 {
+		return errors.New("template must contain between 1 and 100 items")
+	}
+
+	for i := range t.Items {
+		item := &t.Items[i]
+		position := "item " + strconv.Itoa(i+1) + ": "
+
+		if strings.TrimSpace(item.Title) == "" {
+			return errors.New(position + "title is required")
+		}
+
+		if item.Completed == "" {
+			item.Completed = "false"
+		}
+		if item.Completed != "true" && item.Completed != "false" {
+			return errors.New(position + "completed must be true or false")
+		}
+
+		if item.DayOffset < -3650 || item.DayOffset > 3650 {
+			return errors.New(position + "day offset must be within 3650 days of the anchor date")
+		}
+	}
+
+	return nil
+}
+
+// Replace {{placeholders}} in text with the supplied values
+func RenderPlaceholders(text string, values map[string]string) (string, error) {
+	var missing []string
+
+	rendered := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		value, ok := values[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", errors.New("missing values for placeholders: " + strings.Join(missing, ", "))
+	}
+
+	return rendered, nil
+}